@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// The canonical hosted zone IDs of AWS services, which alias targets pointing at them must name.
+// See the service endpoint tables in the AWS general reference.
+var (
+	// elbZoneIDs are for Classic and Application Load Balancers, NAME.REGION.elb.amazonaws.com
+	elbZoneIDs = map[string]string{
+		"us-east-1":      "Z35SXDOTRQ7X7K",
+		"us-east-2":      "Z3AADJGX6KTTL2",
+		"us-west-1":      "Z368ELLRRE2KJ0",
+		"us-west-2":      "Z1H1FL5HABSF5",
+		"ca-central-1":   "ZQSVJUPU6J1EY",
+		"eu-west-1":      "Z32O12XQLNTSW2",
+		"eu-west-2":      "ZHURV8PSTC4K8",
+		"eu-west-3":      "Z3Q77PNBQS71R4",
+		"eu-central-1":   "Z215JYRZR1TBD5",
+		"ap-south-1":     "ZP97RAFLXTNZK",
+		"ap-southeast-1": "Z1LMS91P8CMLE5",
+		"ap-southeast-2": "Z1GM3OXH4ZPM65",
+		"ap-northeast-1": "Z14GRHDCWA56QT",
+		"ap-northeast-2": "ZWKZPGTI48KDX",
+		"sa-east-1":      "Z2P70J7HTTTPLU",
+	}
+	// nlbZoneIDs are for Network Load Balancers, NAME.elb.REGION.amazonaws.com
+	nlbZoneIDs = map[string]string{
+		"us-east-1":      "Z26RNL4JYFTOTI",
+		"us-east-2":      "ZLMOA37VPKANP",
+		"us-west-1":      "Z24FKFUX50B4VW",
+		"us-west-2":      "Z18D5FSROUN65G",
+		"eu-west-1":      "Z2IFOLAFXWLO4F",
+		"eu-central-1":   "Z3F0SRJ5LGBH90",
+		"ap-southeast-1": "ZKVM4W9LS7TM",
+		"ap-southeast-2": "ZCT6FZBF4DROD",
+		"ap-northeast-1": "Z31USIVHYNEOWT",
+	}
+	// s3WebsiteZoneIDs are for S3 website endpoints, s3-website-REGION.amazonaws.com or s3-website.REGION.amazonaws.com
+	s3WebsiteZoneIDs = map[string]string{
+		"us-east-1":      "Z3AQBSTGFYJSTF",
+		"us-east-2":      "Z2O1EMRO9K5GLX",
+		"us-west-1":      "Z2F56UZL2M1ACD",
+		"us-west-2":      "Z3BJ6K6RIION7M",
+		"eu-west-1":      "Z1BKCTXD74EZPE",
+		"eu-central-1":   "Z21DNDUVLTQW6Q",
+		"ap-southeast-1": "Z3O0J2DXBE1FTB",
+		"ap-southeast-2": "Z1WCIGYICN2BYD",
+		"ap-northeast-1": "Z2M4EHUR26P7ZW",
+		"sa-east-1":      "Z7KQH4QJS55SO",
+	}
+)
+
+const (
+	cloudFrontZoneID        = "Z2FDTNDATAQYW2"
+	globalAcceleratorZoneID = "Z2BJ6XQ5FK7U4H"
+)
+
+// aliasHostedZoneID infers the hosted zone ID an alias target needs from the DNS name of
+// a load balancer, CloudFront distribution, S3 website endpoint or Global Accelerator
+func aliasHostedZoneID(dnsName string) (string, error) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(dnsName), "."))
+	labels := strings.Split(name, ".")
+	n := len(labels)
+
+	var table map[string]string
+	var region string
+	switch {
+	case strings.HasSuffix(name, ".cloudfront.net"):
+		return cloudFrontZoneID, nil
+	case strings.HasSuffix(name, ".awsglobalaccelerator.com"):
+		return globalAcceleratorZoneID, nil
+	case strings.HasSuffix(name, ".elb.amazonaws.com") && n >= 5:
+		table, region = elbZoneIDs, labels[n-4]
+	case strings.HasSuffix(name, ".amazonaws.com") && n >= 5 && labels[n-4] == "elb":
+		table, region = nlbZoneIDs, labels[n-3]
+	case n >= 3 && strings.HasPrefix(labels[n-3], "s3-website-"):
+		table, region = s3WebsiteZoneIDs, strings.TrimPrefix(labels[n-3], "s3-website-")
+	case n >= 4 && labels[n-4] == "s3-website":
+		table, region = s3WebsiteZoneIDs, labels[n-3]
+	}
+	if table == nil {
+		return "", fmt.Errorf("can't infer the hosted zone of alias target %s, pass -alias-zoneid", dnsName)
+	}
+	id, ok := table[region]
+	if !ok {
+		return "", fmt.Errorf("no known hosted zone for alias target %s in region %s, pass -alias-zoneid", dnsName, region)
+	}
+	return id, nil
+}
+
+// setAlias creates or updates rrs as an alias record set pointing at target in the hosted zone aliasZoneID
+func (c *cli) setAlias(zoneID string, rrs route53.ResourceRecordSet, target, aliasZoneID string) error {
+	rrs.TTL = nil
+	rrs.AliasTarget = &route53.AliasTarget{
+		DNSName:              aws.String(normalizeName(target)),
+		HostedZoneID:         aws.String(aliasZoneID),
+		EvaluateTargetHealth: aws.Boolean(false),
+	}
+	current, err := c.getResourceRecordSet(zoneID, stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if isNotFound(err) {
+		c.dropUnroutedSetID(&rrs)
+		if err := c.checkCNAMEConflict(zoneID, rrs); err != nil {
+			return err
+		}
+	}
+	if err == nil && current.AliasTarget == nil {
+		return fmt.Errorf("%s %s holds values and isn't an alias record set, delete it first", stringValue(rrs.Name), stringValue(rrs.Type))
+	}
+	if err == nil && current.AliasTarget.EvaluateTargetHealth != nil {
+		// keep the existing setting unless -evaluate-target-health overrides it
+		rrs.AliasTarget.EvaluateTargetHealth = current.AliasTarget.EvaluateTargetHealth
+	}
+	return c.upsertResourceRecordSet(zoneID, current, rrs)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAliasHostedZoneID(t *testing.T) {
+	tests := []struct {
+		dnsName string
+		zoneID  string
+	}{
+		// Classic and Application Load Balancers
+		{"my-elb-1234567890.us-east-1.elb.amazonaws.com.", "Z35SXDOTRQ7X7K"},
+		{"internal-my-alb-1234567890.eu-west-1.elb.amazonaws.com", "Z32O12XQLNTSW2"},
+		{"dualstack.my-alb-1234567890.ap-southeast-2.elb.amazonaws.com.", "Z1GM3OXH4ZPM65"},
+		{"My-ELB-1234567890.US-WEST-2.ELB.AMAZONAWS.COM.", "Z1H1FL5HABSF5"},
+		// Network Load Balancers
+		{"my-nlb-0123456789abcdef.elb.us-east-1.amazonaws.com.", "Z26RNL4JYFTOTI"},
+		{"my-nlb-0123456789abcdef.elb.eu-central-1.amazonaws.com", "Z3F0SRJ5LGBH90"},
+		// S3 website endpoints, with a dash or a dot before the region
+		{"www.example.com.s3-website-us-east-1.amazonaws.com.", "Z3AQBSTGFYJSTF"},
+		{"bucket.s3-website-sa-east-1.amazonaws.com", "Z7KQH4QJS55SO"},
+		{"bucket.s3-website.eu-central-1.amazonaws.com.", "Z21DNDUVLTQW6Q"},
+		{"bucket.s3-website.us-east-2.amazonaws.com", "Z2O1EMRO9K5GLX"},
+		// global services
+		{"d111111abcdef8.cloudfront.net.", "Z2FDTNDATAQYW2"},
+		{"a1234567890abcdef.awsglobalaccelerator.com", "Z2BJ6XQ5FK7U4H"},
+	}
+	for _, tt := range tests {
+		zoneID, err := aliasHostedZoneID(tt.dnsName)
+		if err != nil || zoneID != tt.zoneID {
+			t.Errorf("aliasHostedZoneID(%q) = %q, %v, want %q", tt.dnsName, zoneID, err, tt.zoneID)
+		}
+	}
+}
+
+func TestAliasHostedZoneIDUnknown(t *testing.T) {
+	tests := []struct {
+		dnsName string
+		err     string
+	}{
+		{"www.example.com.", "can't infer the hosted zone"},
+		{"my-elb-1234567890.xx-nowhere-1.elb.amazonaws.com.", "no known hosted zone"},
+		{"bucket.s3-website-xx-nowhere-1.amazonaws.com", "no known hosted zone"},
+	}
+	for _, tt := range tests {
+		if zoneID, err := aliasHostedZoneID(tt.dnsName); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("aliasHostedZoneID(%q) = %q, %v, want an error containing %q", tt.dnsName, zoneID, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// defaultRecentWindow is how far back recent looks when -since isn't given
+const defaultRecentWindow = 24 * time.Hour
+
+// auditState is the TTL and values of a record set before or after a change
+type auditState struct {
+	TTL    int64    `json:"ttl,omitempty"`
+	Values []string `json:"values"`
+}
+
+// auditRecord is one line of the -audit-log, a record set change the tool applied
+type auditRecord struct {
+	At       time.Time   `json:"at"`
+	ZoneID   string      `json:"zone_id"`
+	ChangeID string      `json:"change_id"`
+	Action   string      `json:"action"`
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	SetID    string      `json:"setid,omitempty"`
+	Before   *auditState `json:"before,omitempty"`
+	After    *auditState `json:"after,omitempty"`
+}
+
+// auditStateOf describes rrs for the audit log, values are sorted
+func auditStateOf(rrs *route53.ResourceRecordSet) *auditState {
+	if rrs == nil {
+		return nil
+	}
+	s := &auditState{Values: recordValues(*rrs)}
+	sort.Strings(s.Values)
+	if rrs.TTL != nil {
+		s.TTL = *rrs.TTL
+	}
+	return s
+}
+
+// auditRecords describes each change of an applied batch. inverse is what inverseChanges built for it,
+// it holds the state UPSERTs overwrote, nil when it couldn't be looked up.
+func auditRecords(zoneID, changeID string, batch, inverse []route53.Change) []auditRecord {
+	at := time.Now().UTC()
+	var records []auditRecord
+	for i, change := range batch {
+		rrs := change.ResourceRecordSet
+		if rrs == nil {
+			continue
+		}
+		r := auditRecord{
+			At:       at,
+			ZoneID:   zoneID,
+			ChangeID: changeID,
+			Action:   strings.ToUpper(stringValue(change.Action)),
+			Name:     stringValue(rrs.Name),
+			Type:     stringValue(rrs.Type),
+			SetID:    stringValue(rrs.SetIdentifier),
+		}
+		switch r.Action {
+		case "DELETE":
+			r.Before = auditStateOf(rrs)
+		case "CREATE":
+			r.After = auditStateOf(rrs)
+		default:
+			r.After = auditStateOf(rrs)
+			if i < len(inverse) && stringValue(inverse[i].Action) == "UPSERT" {
+				r.Before = auditStateOf(inverse[i].ResourceRecordSet)
+			}
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// appendAudit appends the records to the -audit-log as JSON lines
+func (c *cli) appendAudit(records []auditRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.OpenFile(c.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, stateFilePermissions)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// readAudit reads the records of the audit log at path made at or after since, a missing log has none
+func readAudit(path string, since time.Time) ([]auditRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return filterAudit(f, since)
+}
+
+// filterAudit decodes JSON line audit records from r, keeping those made at or after since
+func filterAudit(r io.Reader, since time.Time) ([]auditRecord, error) {
+	var records []auditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if !record.At.Before(since) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// describe summarizes how the record set changed, e.g. "1.1.1.1,2.2.2.2 -> 1.1.1.1 ttl 300 -> 60"
+func (r auditRecord) describe() string {
+	values := func(s *auditState) string {
+		if s == nil {
+			return "(none)"
+		}
+		return strings.Join(s.Values, ",")
+	}
+	if r.Before == nil && r.After == nil {
+		return ""
+	}
+	s := values(r.Before) + " -> " + values(r.After)
+	if r.Before != nil && r.After != nil && r.Before.TTL != r.After.TTL {
+		s += fmt.Sprintf(" ttl %d -> %d", r.Before.TTL, r.After.TTL)
+	}
+	return s
+}
+
+// printRecent writes the record sets changed within the window from the -audit-log,
+// as JSON with -output=json and as a table otherwise
+func (c *cli) printRecent(window time.Duration, format string) error {
+	records, err := readAudit(c.auditLog, time.Now().Add(-window))
+	if err != nil {
+		return fmt.Errorf("reading audit log %s %w", c.auditLog, err)
+	}
+	if len(records) == 0 {
+		c.log.Printf("no record set changes logged in %s within the last %s\n", c.auditLog, window)
+	}
+	if format == outputJSON {
+		if records == nil {
+			records = []auditRecord{}
+		}
+		return writeJSON(c.out, records)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	rows := [][]string{{"AT", "ZONEID", "ACTION", "NAME", "TYPE", "SETID", "CHANGE"}}
+	for _, r := range records {
+		rows = append(rows, []string{r.At.Format(time.RFC3339), r.ZoneID, r.Action, r.Name, r.Type, r.SetID, r.describe()})
+	}
+	return writeTable(c.out, colorEnabled(c.out), rows)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterAuditSince(t *testing.T) {
+	log := `{"at":"2026-10-13T09:00:00Z","zone_id":"Z1","action":"UPSERT","name":"old.example.com.","type":"A"}
+{"at":"2026-10-14T11:59:59Z","zone_id":"Z1","action":"UPSERT","name":"before.example.com.","type":"A"}
+
+{"at":"2026-10-14T12:00:00Z","zone_id":"Z1","action":"CREATE","name":"at.example.com.","type":"A","after":{"ttl":300,"values":["192.0.2.1"]}}
+{"at":"2026-10-15T08:30:00Z","zone_id":"Z2","action":"DELETE","name":"after.example.org.","type":"CNAME"}
+`
+	since := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	records, err := filterAudit(strings.NewReader(log), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range records {
+		names = append(names, r.Name)
+	}
+	if got, want := strings.Join(names, " "), "at.example.com. after.example.org."; got != want {
+		t.Errorf("records within the window %s, want %s", got, want)
+	}
+
+	if _, err := filterAudit(strings.NewReader(log+"not json\n"), since); err == nil || !strings.HasPrefix(err.Error(), "line 6:") {
+		t.Errorf("error %v, want one for line 6", err)
+	}
+}
+
+func TestReadAuditMissingLog(t *testing.T) {
+	records, err := readAudit(filepath.Join(t.TempDir(), "audit.log"), time.Time{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("readAudit() = %v, %v, want no records and no error", records, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// outputAWSCLI writes change batches in the --change-batch file format of aws route53 change-resource-record-sets
+const outputAWSCLI = "awscli"
+
+// awscliChangeBatch mirrors the JSON the AWS CLI takes as --change-batch, field names and nesting must match it exactly
+type awscliChangeBatch struct {
+	Comment string         `json:"Comment,omitempty"`
+	Changes []awscliChange `json:"Changes"`
+}
+
+type awscliChange struct {
+	Action            string          `json:"Action"`
+	ResourceRecordSet awscliRecordSet `json:"ResourceRecordSet"`
+}
+
+type awscliRecordSet struct {
+	Name             string             `json:"Name"`
+	Type             string             `json:"Type"`
+	SetIdentifier    string             `json:"SetIdentifier,omitempty"`
+	Weight           *int64             `json:"Weight,omitempty"`
+	Region           string             `json:"Region,omitempty"`
+	GeoLocation      *awscliGeoLocation `json:"GeoLocation,omitempty"`
+	Failover         string             `json:"Failover,omitempty"`
+	MultiValueAnswer *bool              `json:"MultiValueAnswer,omitempty"`
+	TTL              *int64             `json:"TTL,omitempty"`
+	ResourceRecords  []awscliRecord     `json:"ResourceRecords,omitempty"`
+	AliasTarget      *awscliAliasTarget `json:"AliasTarget,omitempty"`
+	HealthCheckID    string             `json:"HealthCheckId,omitempty"`
+}
+
+type awscliRecord struct {
+	Value string `json:"Value"`
+}
+
+type awscliGeoLocation struct {
+	ContinentCode   string `json:"ContinentCode,omitempty"`
+	CountryCode     string `json:"CountryCode,omitempty"`
+	SubdivisionCode string `json:"SubdivisionCode,omitempty"`
+}
+
+type awscliAliasTarget struct {
+	HostedZoneID         string `json:"HostedZoneId"`
+	DNSName              string `json:"DNSName"`
+	EvaluateTargetHealth bool   `json:"EvaluateTargetHealth"`
+}
+
+// awscliBatch converts a change batch to the AWS CLI format
+func awscliBatch(batch route53.ChangeBatch) awscliChangeBatch {
+	out := awscliChangeBatch{Comment: stringValue(batch.Comment), Changes: []awscliChange{}}
+	for _, change := range batch.Changes {
+		var rrs awscliRecordSet
+		if change.ResourceRecordSet != nil {
+			rrs = awscliRecordSetFrom(*change.ResourceRecordSet)
+		}
+		out.Changes = append(out.Changes, awscliChange{Action: stringValue(change.Action), ResourceRecordSet: rrs})
+	}
+	return out
+}
+
+// awscliRecordSetFrom converts a record set to the AWS CLI format
+func awscliRecordSetFrom(rrs route53.ResourceRecordSet) awscliRecordSet {
+	out := awscliRecordSet{
+		Name:             stringValue(rrs.Name),
+		Type:             stringValue(rrs.Type),
+		SetIdentifier:    stringValue(rrs.SetIdentifier),
+		Weight:           rrs.Weight,
+		Region:           stringValue(rrs.Region),
+		Failover:         stringValue(rrs.Failover),
+		MultiValueAnswer: rrs.MultiValueAnswer,
+		TTL:              rrs.TTL,
+		HealthCheckID:    stringValue(rrs.HealthCheckID),
+	}
+	if geo := rrs.GeoLocation; geo != nil {
+		out.GeoLocation = &awscliGeoLocation{
+			ContinentCode:   stringValue(geo.ContinentCode),
+			CountryCode:     stringValue(geo.CountryCode),
+			SubdivisionCode: stringValue(geo.SubdivisionCode),
+		}
+	}
+	for _, rr := range rrs.ResourceRecords {
+		out.ResourceRecords = append(out.ResourceRecords, awscliRecord{Value: stringValue(rr.Value)})
+	}
+	if alias := rrs.AliasTarget; alias != nil {
+		out.AliasTarget = &awscliAliasTarget{
+			HostedZoneID:         stringValue(alias.HostedZoneID),
+			DNSName:              stringValue(alias.DNSName),
+			EvaluateTargetHealth: alias.EvaluateTargetHealth != nil && *alias.EvaluateTargetHealth,
+		}
+	}
+	return out
+}
+
+// printAWSCLIBatch writes a change batch as an AWS CLI --change-batch JSON document
+func printAWSCLIBatch(w io.Writer, batch route53.ChangeBatch) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(awscliBatch(batch))
+}
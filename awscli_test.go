@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// TestPrintAWSCLIBatchGolden compares the output with a --change-batch file as written for the AWS CLI
+func TestPrintAWSCLIBatchGolden(t *testing.T) {
+	upsert := testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2")
+	upsert.SetIdentifier = aws.String("dc1")
+	upsert.Weight = aws.Long(10)
+	upsert.HealthCheckID = aws.String("abcdef11-2222-3333-4444-555555fedcba")
+	del := testRecordSet("old.example.com.", "CNAME", 60, "www.example.com.")
+	batch := route53.ChangeBatch{
+		Comment: aws.String("dc1 cutover"),
+		Changes: []route53.Change{
+			{Action: aws.String("UPSERT"), ResourceRecordSet: &upsert},
+			{Action: aws.String("DELETE"), ResourceRecordSet: &del},
+		},
+	}
+	var buf bytes.Buffer
+	if err := printAWSCLIBatch(&buf, batch); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/awscli-change-batch.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+}
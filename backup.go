@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// defaultBackupDir is where -backup writes when -backup-dir isn't given
+func defaultBackupDir() string {
+	return filepath.Join(stateDir(), "backups")
+}
+
+// backup remembers the current state of record sets about to be changed destructively.
+// They are written by writeBackup right before the changes are submitted.
+func (c *cli) backup(sets ...route53.ResourceRecordSet) {
+	if c.backupDir == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pendingBackup = append(c.pendingBackup, sets...)
+}
+
+// writeBackup writes the record sets remembered by backup to a timestamped file in -backup-dir,
+// in the -file batch schema so applying the file with -file restores them.
+// Alias record sets have no batch form, so changing one fails rather than leave it out of the backup.
+func (c *cli) writeBackup() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pendingBackup) == 0 {
+		return nil
+	}
+	for _, rrs := range c.pendingBackup {
+		if rrs.AliasTarget != nil {
+			return fmt.Errorf("alias record set %s %s can't be backed up, change it without -backup", stringValue(rrs.Name), stringValue(rrs.Type))
+		}
+	}
+	format, write := outputJSON, printJSON
+	if c.backupFormat == outputYAML {
+		format, write = outputYAML, printYAML
+	}
+	if err := os.MkdirAll(c.backupDir, stateDirPermissions); err != nil {
+		return err
+	}
+	path := filepath.Join(c.backupDir, fmt.Sprintf("backup-%s.%s", time.Now().UTC().Format("20060102T150405.000000000Z"), format))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stateFilePermissions)
+	if err != nil {
+		return err
+	}
+	if err := write(f, c.pendingBackup); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	c.log.Printf("backed up %d record set(s) to %s, restore them with -file=%s\n", len(c.pendingBackup), path, path)
+	c.pendingBackup = nil
+	return nil
+}
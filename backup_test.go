@@ -0,0 +1,118 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// routedRecordSets has a record set of each routing policy, with health checks
+func routedRecordSets() []route53.ResourceRecordSet {
+	simple := testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2")
+	weighted := testRecordSet("w.example.com.", "A", 60, "192.0.2.3")
+	weighted.SetIdentifier = aws.String("dc1")
+	weighted.Weight = aws.Long(10)
+	multi := testRecordSet("m.example.com.", "A", 60, "192.0.2.4")
+	multi.SetIdentifier = aws.String("m1")
+	multi.MultiValueAnswer = aws.Boolean(true)
+	multi.HealthCheckID = aws.String("hc-multi")
+	latency := testRecordSet("api.example.com.", "A", 60, "192.0.2.5")
+	latency.SetIdentifier = aws.String("us-east-1")
+	latency.Region = aws.String("us-east-1")
+	failover := testRecordSet("db.example.com.", "CNAME", 30, "db1.example.net.")
+	failover.SetIdentifier = aws.String("primary")
+	failover.Failover = aws.String("PRIMARY")
+	failover.HealthCheckID = aws.String("hc-db1")
+	geo := testRecordSet("geo.example.com.", "A", 60, "192.0.2.6")
+	geo.SetIdentifier = aws.String("us-ca")
+	geo.GeoLocation = &route53.GeoLocation{CountryCode: aws.String("US"), SubdivisionCode: aws.String("CA")}
+	return []route53.ResourceRecordSet{simple, weighted, multi, latency, failover, geo}
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	for _, format := range []string{outputJSON, outputYAML} {
+		t.Run(format, func(t *testing.T) {
+			c, _, _ := newTestCLI(t, newFakeRoute53(nil))
+			c.backupDir = t.TempDir()
+			c.backupFormat = format
+			sets := routedRecordSets()
+			c.backup(sets...)
+			if err := c.writeBackup(); err != nil {
+				t.Fatal(err)
+			}
+			paths, err := filepath.Glob(filepath.Join(c.backupDir, "backup-*"))
+			if err != nil || len(paths) != 1 {
+				t.Fatalf("backup files %v %v, want one", paths, err)
+			}
+			entries, err := readBatchFile(paths[0], "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(sets) {
+				t.Fatalf("restored %d entries, want %d", len(entries), len(sets))
+			}
+			for i, e := range entries {
+				if err := e.validate(); err != nil {
+					t.Errorf("entry %s: %s", e, err)
+				}
+				if got, want := canonicalRecordSet(e.recordSet()), canonicalRecordSet(sets[i]); got != want {
+					t.Errorf("restored\n%s\nwant\n%s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackupRefusesAlias(t *testing.T) {
+	c, _, _ := newTestCLI(t, newFakeRoute53(nil))
+	c.backupDir = t.TempDir()
+	alias := route53.ResourceRecordSet{
+		Name:        aws.String("www.example.com."),
+		Type:        aws.String("A"),
+		AliasTarget: &route53.AliasTarget{DNSName: aws.String("lb.example.net."), HostedZoneID: aws.String("Z2")},
+	}
+	c.backup(alias)
+	if err := c.writeBackup(); err == nil || !strings.Contains(err.Error(), "can't be backed up") {
+		t.Fatalf("writeBackup() = %v, want an error refusing the alias record set", err)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(c.backupDir, "backup-*")); len(paths) != 0 {
+		t.Errorf("wrote %v for an alias record set", paths)
+	}
+}
+
+func TestBatchEntryValidateRouting(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry batchEntry
+		err   string
+	}{
+		{"latency", batchEntry{SetID: "a", Region: "us-east-1"}, ""},
+		{"latency without setid", batchEntry{Region: "us-east-1"}, "need a setid"},
+		{"two policies", batchEntry{SetID: "a", Region: "us-east-1", Weight: aws.Long(1)}, "only one of"},
+		{"failover", batchEntry{SetID: "a", Failover: "secondary"}, ""},
+		{"bad failover", batchEntry{SetID: "a", Failover: "tertiary"}, "PRIMARY or SECONDARY"},
+		{"geo country", batchEntry{SetID: "a", GeoLocation: &batchGeo{Country: "us", Subdivision: "ca"}}, ""},
+		{"geo default", batchEntry{SetID: "a", GeoLocation: &batchGeo{Country: "*"}}, ""},
+		{"geo empty", batchEntry{SetID: "a", GeoLocation: &batchGeo{}}, "continent or a country"},
+		{"geo continent and country", batchEntry{SetID: "a", GeoLocation: &batchGeo{Continent: "NA", Country: "US"}}, "not both"},
+		{"geo subdivision without country", batchEntry{SetID: "a", GeoLocation: &batchGeo{Continent: "NA", Subdivision: "CA"}}, "needs its country"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.entry
+			e.Name = "www.example.com"
+			e.Values = []string{"192.0.2.1"}
+			e.normalize()
+			err := e.validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("validate() = %v, want no error", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+const baselinesFile = "baselines.json"
+
+// baseline is the state a record set was left in by the last apply
+type baseline struct {
+	TTL    int64     `json:"ttl,omitempty"`
+	Values []string  `json:"values"`
+	At     time.Time `json:"at"`
+}
+
+// baselineOf describes the state of rrs, values are sorted so the order Route53 returns them in doesn't matter
+func baselineOf(rrs route53.ResourceRecordSet) baseline {
+	b := baseline{Values: recordValues(rrs), At: time.Now()}
+	sort.Strings(b.Values)
+	if rrs.TTL != nil {
+		b.TTL = *rrs.TTL
+	}
+	return b
+}
+
+// same reports whether two baselines describe the same state, whenever they were taken
+func (b baseline) same(other baseline) bool {
+	return b.TTL == other.TTL && strings.Join(b.Values, "\n") == strings.Join(other.Values, "\n")
+}
+
+// baselines maps record sets to the state the last apply left them in, so changes made since
+// in the console or by other tools are noticed before they are overwritten
+type baselines struct {
+	path string
+	Sets map[string]baseline `json:"record_sets"`
+}
+
+// baselineKey identifies a record set in a zone
+func baselineKey(zoneID string, rrs route53.ResourceRecordSet) string {
+	return strings.Join([]string{zoneID, strings.ToLower(stringValue(rrs.Name)), stringValue(rrs.Type), stringValue(rrs.SetIdentifier)}, " ")
+}
+
+// loadBaselines reads the baseline state, a missing file is treated as empty
+func loadBaselines() (*baselines, error) {
+	b := &baselines{
+		path: filepath.Join(stateDir(), baselinesFile),
+		Sets: make(map[string]baseline),
+	}
+	data, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	if b.Sets == nil {
+		b.Sets = make(map[string]baseline)
+	}
+	return b, nil
+}
+
+// save writes the baseline state
+func (b *baselines) save() error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), stateDirPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(b.path, data, stateFilePermissions)
+}
+
+// checkDrift warns about each record set the changes touch whose live state differs from what the last apply left,
+// meaning someone changed it out of band. Only record sets with a baseline are fetched.
+func (c *cli) checkDrift(zoneID string, changes []route53.Change) {
+	c.mu.Lock()
+	recorded, err := loadBaselines()
+	c.mu.Unlock()
+	if err != nil {
+		c.log.Println("WARNING could not read baselines", err)
+		return
+	}
+	for _, change := range changes {
+		rrs := change.ResourceRecordSet
+		if rrs == nil {
+			continue
+		}
+		b, exists := recorded.Sets[baselineKey(zoneID, *rrs)]
+		if !exists {
+			continue
+		}
+		id := fmt.Sprintf("%s %s", stringValue(rrs.Name), stringValue(rrs.Type))
+		if rrs.SetIdentifier != nil {
+			id += " setIdentifier=" + *rrs.SetIdentifier
+		}
+		live, err := c.getResourceRecordSet(zoneID, stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+		switch {
+		case isNotFound(err):
+			c.log.Printf("⚠ record modified since last apply: %s was deleted after %s\n", id, b.At.Format(time.RFC3339))
+		case err != nil:
+			c.log.Printf("WARNING could not check %s for drift: %s\n", id, err)
+		case !baselineOf(live).same(b):
+			now := baselineOf(live)
+			c.log.Printf("⚠ record modified since last apply: %s was ttl=%d %s at %s, is ttl=%d %s\n", id, b.TTL, strings.Join(b.Values, ","), b.At.Format(time.RFC3339), now.TTL, strings.Join(now.Values, ","))
+		}
+	}
+}
+
+// recordBaselines stores the state submitted changes leave their record sets in
+func (c *cli) recordBaselines(zoneID string, changes []route53.Change) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	recorded, err := loadBaselines()
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		rrs := change.ResourceRecordSet
+		if rrs == nil {
+			continue
+		}
+		key := baselineKey(zoneID, *rrs)
+		if stringValue(change.Action) == "DELETE" {
+			delete(recorded.Sets, key)
+			continue
+		}
+		recorded.Sets[key] = baselineOf(*rrs)
+	}
+	return recorded.save()
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+	"gopkg.in/yaml.v2"
+)
+
+// batchEntry is one change read from a -file batch, in JSON or YAML.
+// Action is one of add, del, upsert, create or delete and defaults to upsert,
+// which makes the record set hold exactly Values. The same schema is written by
+// -output=yaml so a listing can be edited and applied again, along with the
+// routing policy and health check of the record set.
+type batchEntry struct {
+	Action      string    `json:"action,omitempty" yaml:"action,omitempty"`
+	Name        string    `json:"name" yaml:"name"`
+	Type        string    `json:"type,omitempty" yaml:"type,omitempty"`
+	SetID       string    `json:"setid,omitempty" yaml:"setid,omitempty"`
+	TTL         int64     `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Weight      *int64    `json:"weight,omitempty" yaml:"weight,omitempty"`
+	MultiValue  bool      `json:"multivalue,omitempty" yaml:"multivalue,omitempty"`
+	Region      string    `json:"region,omitempty" yaml:"region,omitempty"`
+	Failover    string    `json:"failover,omitempty" yaml:"failover,omitempty"`
+	GeoLocation *batchGeo `json:"geolocation,omitempty" yaml:"geolocation,omitempty"`
+	HealthCheck string    `json:"health_check_id,omitempty" yaml:"health_check_id,omitempty"`
+	Values      []string  `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// batchGeo is the location a geolocation record set answers for, e.g. a continent or a country and one of its subdivisions
+type batchGeo struct {
+	Continent   string `json:"continent,omitempty" yaml:"continent,omitempty"`
+	Country     string `json:"country,omitempty" yaml:"country,omitempty"`
+	Subdivision string `json:"subdivision,omitempty" yaml:"subdivision,omitempty"`
+}
+
+// entryFromRecordSet describes an existing record set as a batch entry, alias record sets can't be described
+func entryFromRecordSet(rrs route53.ResourceRecordSet) (batchEntry, bool) {
+	if rrs.AliasTarget != nil {
+		return batchEntry{}, false
+	}
+	e := batchEntry{
+		Name:        stringValue(rrs.Name),
+		Type:        stringValue(rrs.Type),
+		SetID:       stringValue(rrs.SetIdentifier),
+		MultiValue:  isMultiValue(rrs),
+		Region:      stringValue(rrs.Region),
+		Failover:    stringValue(rrs.Failover),
+		HealthCheck: stringValue(rrs.HealthCheckID),
+	}
+	if rrs.TTL != nil {
+		e.TTL = *rrs.TTL
+	}
+	if rrs.Weight != nil {
+		e.Weight = aws.Long(*rrs.Weight)
+	}
+	if geo := rrs.GeoLocation; geo != nil {
+		e.GeoLocation = &batchGeo{
+			Continent:   stringValue(geo.ContinentCode),
+			Country:     stringValue(geo.CountryCode),
+			Subdivision: stringValue(geo.SubdivisionCode),
+		}
+	}
+	for _, rr := range rrs.ResourceRecords {
+		e.Values = append(e.Values, stringValue(rr.Value))
+	}
+	return e, true
+}
+
+// String identifies the entry in log messages
+func (e batchEntry) String() string {
+	s := fmt.Sprintf("%s %s %s", e.Action, e.Name, e.Type)
+	if e.SetID != "" {
+		s += " setid=" + e.SetID
+	}
+	return s
+}
+
+// batchFormat picks the format of a batch file from its extension when not given: .yaml and .yml are YAML,
+// .csv is CSV and anything else JSON
+func batchFormat(path, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".csv":
+		return "csv"
+	}
+	return "json"
+}
+
+// readBatchFile loads the entries of a batch file in the given format, see batchFormat
+func readBatchFile(path, format string) ([]batchEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []batchEntry
+	switch batchFormat(path, format) {
+	case "yaml":
+		err = yaml.Unmarshal(data, &entries)
+	case "csv":
+		entries, err = parseCSVBatch(bytes.NewReader(data))
+	case "json":
+		err = json.Unmarshal(data, &entries)
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	for i := range entries {
+		entries[i].normalize()
+	}
+	return entries, nil
+}
+
+// normalize fills in defaults so entries can be compared and validated consistently
+func (e *batchEntry) normalize() {
+	e.Action = strings.ToLower(e.Action)
+	if e.Action == "" {
+		e.Action = "upsert"
+	}
+	e.Type = normalizeType(e.Type)
+	if e.Type == "" {
+		e.Type = "A"
+	}
+	e.Name = normalizeName(e.Name)
+	e.Failover = strings.ToUpper(e.Failover)
+	if geo := e.GeoLocation; geo != nil {
+		geo.Continent = strings.ToUpper(geo.Continent)
+		geo.Country = strings.ToUpper(geo.Country)
+		geo.Subdivision = strings.ToUpper(geo.Subdivision)
+	}
+	for i, v := range e.Values {
+		e.Values[i] = canonicalValue(e.Type, v)
+	}
+}
+
+// validate checks the entry is well formed without contacting AWS
+func (e batchEntry) validate() error {
+	if e.Name == "." {
+		return fmt.Errorf("name is required")
+	}
+	if !supportedType(e.Type) {
+		return fmt.Errorf("unsupported record type %s", e.Type)
+	}
+	switch e.Action {
+	case "add", "del", "upsert", "create":
+		if len(e.Values) == 0 {
+			return fmt.Errorf("%s needs one or more values", e.Action)
+		}
+	case "delete":
+	default:
+		return fmt.Errorf("unknown action %q", e.Action)
+	}
+	if e.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if err := validateSetID(e.SetID); err != nil {
+		return err
+	}
+	policies := 0
+	for _, set := range []bool{e.Weight != nil, e.MultiValue, e.Region != "", e.Failover != "", e.GeoLocation != nil} {
+		if set {
+			policies++
+		}
+	}
+	if policies > 1 {
+		return fmt.Errorf("only one of weight, multivalue, region, failover and geolocation can be given")
+	}
+	if policies == 1 && e.SetID == "" {
+		return fmt.Errorf("weighted, multivalue, latency, failover and geolocation record sets need a setid")
+	}
+	if e.Failover != "" && e.Failover != "PRIMARY" && e.Failover != "SECONDARY" {
+		return fmt.Errorf("failover must be PRIMARY or SECONDARY, not %s", e.Failover)
+	}
+	if geo := e.GeoLocation; geo != nil {
+		switch {
+		case geo.Continent == "" && geo.Country == "":
+			return fmt.Errorf("geolocation needs a continent or a country")
+		case geo.Continent != "" && geo.Country != "":
+			return fmt.Errorf("geolocation takes a continent or a country, not both")
+		case geo.Subdivision != "" && geo.Country == "":
+			return fmt.Errorf("a geolocation subdivision needs its country")
+		}
+	}
+	return nil
+}
+
+// checkEntries runs every client side check on the entries without contacting AWS, returning a problem per invalid entry.
+// The values, weights and the record sets upsert and create entries make are checked besides the entry itself.
+func (c *cli) checkEntries(entries []batchEntry) []error {
+	var errs []error
+	for i, e := range entries {
+		if err := c.checkEntry(e); err != nil {
+			errs = append(errs, fmt.Errorf("entry %d (%s): %s", i+1, e, err))
+		}
+	}
+	return errs
+}
+
+// checkEntry is checkEntries for a single entry
+func (c *cli) checkEntry(e batchEntry) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	if e.Weight != nil && (*e.Weight < 0 || *e.Weight > maxWeight) {
+		return fmt.Errorf("weight must be between 0 and %d", maxWeight)
+	}
+	if e.Action == "del" || e.Action == "delete" {
+		return nil
+	}
+	for _, v := range e.Values {
+		if err := validateValue(e.Type, v); err != nil {
+			return err
+		}
+	}
+	if e.Action == "add" {
+		return nil
+	}
+	return c.validateRecordSet(e.recordSet())
+}
+
+// recordSet builds the record set described by the entry
+func (e batchEntry) recordSet() route53.ResourceRecordSet {
+	rrs := newResourceRecordSet(e.Name, e.Type, e.SetID, e.TTL)
+	if e.Weight != nil {
+		rrs.Weight = aws.Long(*e.Weight)
+	}
+	if e.MultiValue {
+		rrs.MultiValueAnswer = aws.Boolean(true)
+	}
+	if e.Region != "" {
+		rrs.Region = aws.String(e.Region)
+	}
+	if e.Failover != "" {
+		rrs.Failover = aws.String(e.Failover)
+	}
+	if geo := e.GeoLocation; geo != nil {
+		rrs.GeoLocation = &route53.GeoLocation{}
+		if geo.Continent != "" {
+			rrs.GeoLocation.ContinentCode = aws.String(geo.Continent)
+		}
+		if geo.Country != "" {
+			rrs.GeoLocation.CountryCode = aws.String(geo.Country)
+		}
+		if geo.Subdivision != "" {
+			rrs.GeoLocation.SubdivisionCode = aws.String(geo.Subdivision)
+		}
+	}
+	if e.HealthCheck != "" {
+		rrs.HealthCheckID = aws.String(e.HealthCheck)
+	}
+	for _, v := range e.Values {
+		rrs.ResourceRecords = append(rrs.ResourceRecords, route53.ResourceRecord{Value: aws.String(v)})
+	}
+	return rrs
+}
+
+// nameInZone reports whether name is the zone apex or a name below it
+func nameInZone(name, zone string) bool {
+	name = strings.ToLower(unescapeName(normalizeName(name)))
+	zone = strings.ToLower(unescapeName(normalizeName(zone)))
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// filterZone keeps the entries for names in the zone, returning how many were left out.
+// With lookup, names below it that are delegated to a hosted zone of their own are left out as well,
+// entries whose zone can't be looked up are kept so applying them reports why.
+func (c *cli) filterZone(entries []batchEntry, zone string, lookup bool) ([]batchEntry, int) {
+	var kept []batchEntry
+	for _, e := range entries {
+		if !nameInZone(e.Name, zone) {
+			continue
+		}
+		if lookup {
+			if ref, err := c.lookupZone(e.Name); err == nil && !sameName(ref.name, normalizeName(zone)) {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	return kept, len(entries) - len(kept)
+}
+
+// batchResult collects the outcome of applying a batch
+type batchResult struct {
+	applied int
+	skipped int
+	errs    []error
+
+	// interrupted is set when a signal stopped the batch, pending counts the changes not submitted
+	interrupted bool
+	pending     int
+	// zones counts the hosted zones changes were submitted to
+	zones int
+}
+
+func (r *batchResult) fail(err error) {
+	r.errs = append(r.errs, err)
+}
+
+// summary describes the outcome of a run in one line
+func (r batchResult) summary(elapsed time.Duration) string {
+	return fmt.Sprintf("%d changes applied, %d skipped (no-op), %d failed across %d zones in %s", r.applied, r.skipped, len(r.errs), r.zones, elapsed.Round(time.Millisecond))
+}
+
+// zoneChanges groups the changes of a batch destined for one hosted zone
+type zoneChanges struct {
+	zoneID  string
+	changes []route53.Change
+}
+
+// batchChange turns an entry into the change to submit, returning nil when the record set is already as requested
+func (c *cli) batchChange(zoneID string, e batchEntry) (*route53.Change, error) {
+	switch e.Action {
+	case "create":
+		rrs := e.recordSet()
+		if err := c.validateRecordSet(rrs); err != nil {
+			return nil, err
+		}
+		return c.createChange(zoneID, rrs)
+	case "upsert":
+		desired := e.recordSet()
+		current, err := c.getResourceRecordSet(zoneID, e.Name, e.Type, e.SetID)
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		if err == nil && e.TTL == 0 {
+			// keep the existing TTL rather than resetting it to the default
+			desired.TTL = current.TTL
+		}
+		if err == nil && sameResourceRecordSet(current, desired) {
+			return nil, nil
+		}
+		if err == nil {
+			c.backup(current)
+		}
+		if err := c.validateRecordSet(desired); err != nil {
+			return nil, err
+		}
+		if isNotFound(err) {
+			if err := c.checkCNAMEConflict(zoneID, desired); err != nil {
+				return nil, err
+			}
+			c.notePrior(&desired, nil)
+		} else {
+			c.notePrior(&desired, &current)
+		}
+		return &route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &desired}, nil
+	}
+
+	current, err := c.getResourceRecordSet(zoneID, e.Name, e.Type, e.SetID)
+	if err != nil {
+		return nil, err
+	}
+	var updated route53.ResourceRecordSet
+	switch e.Action {
+	case "add":
+		ips, err := c.preflightIPs(e.Values)
+		if err != nil {
+			return nil, err
+		}
+		updated = c.withValuesAdded(current, ips...)
+	case "del":
+		c.backup(current)
+		updated = c.withValuesRemoved(current, e.Values...)
+	case "delete":
+		c.backup(current)
+		return &route53.Change{Action: aws.String("DELETE"), ResourceRecordSet: &current}, nil
+	}
+	return c.upsertChange(current, updated)
+}
+
+// runBatch applies the entries, grouping their changes per hosted zone.
+// Unless failFast is set, failures are collected and the remaining entries still processed.
+func (c *cli) runBatch(entries []batchEntry, zoneID string) batchResult {
+	var result batchResult
+	var zones []*zoneChanges
+	byZone := make(map[string]*zoneChanges)
+
+	for i, e := range entries {
+		if c.interrupted() {
+			result.interrupted = true
+			result.pending = len(entries) - i
+			for _, zc := range zones {
+				result.pending += len(zc.changes)
+			}
+			return result
+		}
+		if err := c.checkEntry(e); err != nil {
+			c.countFailed(1)
+			result.fail(fmt.Errorf("entry %d (%s): %s", i+1, e, err))
+			if c.failFast {
+				return result
+			}
+			continue
+		}
+		entryZoneID := zoneID
+		if entryZoneID == "" {
+			var err error
+			entryZoneID, err = c.zoneIDByName(e.Name)
+			if err != nil {
+				c.countFailed(1)
+				result.fail(fmt.Errorf("entry %d (%s): %s", i+1, e, zoneLookupError(err)))
+				if c.failFast {
+					return result
+				}
+				continue
+			}
+		}
+		change, err := c.batchChange(entryZoneID, e)
+		if err != nil {
+			c.countFailed(1)
+			result.fail(fmt.Errorf("entry %d (%s): %s", i+1, e, err))
+			if c.failFast {
+				return result
+			}
+			continue
+		}
+		if change == nil {
+			if c.verbose {
+				c.log.Printf("entry %d (%s): no change needed\n", i+1, e)
+			}
+			result.skipped++
+			continue
+		}
+		zc, exists := byZone[entryZoneID]
+		if !exists {
+			zc = &zoneChanges{zoneID: entryZoneID}
+			byZone[entryZoneID] = zc
+			zones = append(zones, zc)
+		}
+		zc.changes = append(zc.changes, *change)
+	}
+
+	for i, o := range c.submitZones(zones) {
+		zc := zones[i]
+		result.applied += o.submitted
+		if o.ran {
+			result.zones++
+		}
+		switch {
+		case !o.ran:
+			// skipped after an interruption or, with failFast, a failure in another zone
+			if result.interrupted {
+				result.pending += len(zc.changes)
+			}
+		case o.err == errInterrupted:
+			result.interrupted = true
+			result.pending += len(zc.changes) - o.submitted
+		case o.err != nil:
+			result.fail(fmt.Errorf("zoneID=%s: %s", zc.zoneID, o.err))
+		}
+	}
+	return result
+}
+
+// zoneOutcome is the result of submitting the changes of one zone
+type zoneOutcome struct {
+	ran       bool
+	submitted int
+	err       error
+}
+
+// submitZones submits the changes of each zone, c.parallelZones zones at a time.
+// Zones not started yet are skipped once one is interrupted or, with failFast, fails.
+func (c *cli) submitZones(zones []*zoneChanges) []zoneOutcome {
+	outcomes := make([]zoneOutcome, len(zones))
+	parallel := c.parallelZones
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	var stopped int32
+	var wg sync.WaitGroup
+	for i, zc := range zones {
+		slots <- struct{}{}
+		if atomic.LoadInt32(&stopped) != 0 {
+			break
+		}
+		wg.Add(1)
+		go func(o *zoneOutcome, zc *zoneChanges) {
+			defer wg.Done()
+			o.ran = true
+			o.submitted, o.err = c.submitBatches(zc.zoneID, zc.changes)
+			if o.err == errInterrupted || (o.err != nil && c.failFast) {
+				atomic.StoreInt32(&stopped, 1)
+			}
+			<-slots
+		}(&outcomes[i], zc)
+	}
+	wg.Wait()
+	return outcomes
+}
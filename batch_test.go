@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+func TestCheckEntriesReportsEveryInvalidEntry(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	c, _, _ := newTestCLI(t, nil)
+	// without a Route53 client any AWS call would panic
+	c.r53 = nil
+	c.maxValues = 2
+	c.ttlMax = 3600
+
+	entries := []batchEntry{
+		{Name: "ok.example.com", Values: []string{"192.0.2.1"}},
+		{Name: "badip.example.com", Values: []string{"192.0.2.300"}},
+		{Name: "novalues.example.com"},
+		{Name: "weight.example.com", SetID: "dc1", Weight: aws.Long(256), Values: []string{"192.0.2.1"}},
+		{Name: "mx.example.com", Type: "MX", Values: []string{"10 mail.example.com."}},
+		{Name: "ttl.example.com", TTL: 86400, Values: []string{"192.0.2.1"}},
+		{Name: "many.example.com", Values: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{Name: "del.example.com", Action: "delete"},
+		{Name: "caa.example.com", Type: "CAA", Values: []string{`0 issue "ca.example; account=1"`}},
+	}
+	for i := range entries {
+		entries[i].normalize()
+	}
+	errs := c.checkEntries(entries)
+	want := []string{
+		"entry 2 (upsert badip.example.com. A)",
+		"entry 3 (upsert novalues.example.com. A)",
+		"entry 4 (upsert weight.example.com. A setid=dc1)",
+		"entry 5 (upsert mx.example.com. MX)",
+		"entry 6 (upsert ttl.example.com. A)",
+		"entry 7 (upsert many.example.com. A)",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("%d problems %v, want %d", len(errs), errs, len(want))
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), want[i]) {
+			t.Errorf("problem %d = %q, want it about %s", i+1, err, want[i])
+		}
+	}
+
+	// a CSV file is checked the same way, its invalid rows don't stop the ones after them being checked
+	path := filepath.Join(t.TempDir(), "batch.csv")
+	csv := "action,name,type,values\n" +
+		"add,ok.example.com,A,192.0.2.1\n" +
+		"add,badip.example.com,A,192.0.2.300\n" +
+		"frobnicate,action.example.com,A,192.0.2.1\n" +
+		"upsert,mx.example.com,MX,10 mail.example.com.\n" +
+		"delete,del.example.com,A,\n"
+	if err := ioutil.WriteFile(path, []byte(csv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readBatchFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs = c.checkEntries(entries)
+	want = []string{
+		"entry 2 (add badip.example.com. A)",
+		"entry 3 (frobnicate action.example.com. A)",
+		"entry 4 (upsert mx.example.com. MX)",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("CSV: %d problems %v, want %d", len(errs), errs, len(want))
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), want[i]) {
+			t.Errorf("CSV: problem %d = %q, want it about %s", i+1, err, want[i])
+		}
+	}
+}
+
+func TestRunBatchParallelZones(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com.", "Z2": "example.org."})
+	fake.add("Z2", testRecordSet("www.example.org.", "A", 300, "192.0.2.10"))
+	fake.zoneErrs = map[string]error{"Z2": aws.APIError{StatusCode: 400, Code: "InvalidChangeBatch", Message: "rejected"}}
+	c, _, _ := newTestCLI(t, fake)
+	c.parallelZones = 2
+	entries := []batchEntry{
+		{Name: "www.example.com", Values: []string{"192.0.2.1"}},
+		{Name: "www.example.org", Action: "add", Values: []string{"192.0.2.11"}},
+		{Name: "api.example.com", Values: []string{"192.0.2.2"}},
+		{Name: "api.example.org", Values: []string{"192.0.2.12"}},
+	}
+	for i := range entries {
+		entries[i].normalize()
+	}
+	result := c.runBatch(entries, "")
+	if result.applied != 2 || result.zones != 2 {
+		t.Errorf("%d applied across %d zones, want the 2 changes of example.com. across 2 zones", result.applied, result.zones)
+	}
+	if len(result.errs) != 1 || !strings.Contains(result.errs[0].Error(), "zoneID=Z2") {
+		t.Fatalf("errors %v, want one for zoneID=Z2", result.errs)
+	}
+	byZone := make(map[string][]string)
+	for _, req := range fake.requests {
+		zoneID := stringValue(req.HostedZoneID)
+		byZone[zoneID] = append(byZone[zoneID], changesOf([]*route53.ChangeResourceRecordSetsRequest{req})...)
+	}
+	want := map[string][]string{
+		"Z1": {"UPSERT www.example.com. A", "UPSERT api.example.com. A"},
+		"Z2": {"UPSERT www.example.org. A", "UPSERT api.example.org. A"},
+	}
+	if !reflect.DeepEqual(byZone, want) {
+		t.Errorf("changes by zone %v, want %v", byZone, want)
+	}
+	if fake.listZonesCalls != 1 {
+		t.Errorf("%d ListHostedZones calls for %d entries, want 1", fake.listZonesCalls, len(entries))
+	}
+}
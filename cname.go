@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// checkCNAMEConflict refuses creating rrs when its name already has record sets Route53 won't let it coexist with:
+// a CNAME can't share its name with any other record type, so creating either kind next to the other is rejected.
+func (c *cli) checkCNAMEConflict(zoneID string, rrs route53.ResourceRecordSet) error {
+	name, recordType := stringValue(rrs.Name), stringValue(rrs.Type)
+	sets, err := c.recordSetsByName(zoneID, name, "")
+	if err != nil {
+		return fmt.Errorf("checking record sets at %s %w", name, err)
+	}
+	for _, existing := range sets {
+		existingType := stringValue(existing.Type)
+		if existingType == recordType || (existingType != "CNAME" && recordType != "CNAME") {
+			continue
+		}
+		return fmt.Errorf("%s already has a %s record set, Route53 doesn't allow a CNAME next to other record types at the same name, can't create the %s", name, existingType, recordType)
+	}
+	return nil
+}
+
+// checkCNAMETarget makes sure the target of a CNAME resolves, it is enabled by -resolve-cname
+func (c *cli) checkCNAMETarget(rrs route53.ResourceRecordSet) error {
+	if !c.resolveCNAME || stringValue(rrs.Type) != "CNAME" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, resolverTimeout)
+	defer cancel()
+	for _, target := range recordValues(rrs) {
+		if _, err := dnsResolver(c.resolver).LookupHost(ctx, target); err != nil {
+			return fmt.Errorf("CNAME target %s of %s doesn't resolve: %s", target, stringValue(rrs.Name), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckCNAMEConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		rrs  string
+		typ  string
+		err  string
+	}{
+		{"CNAME next to an A", "www.example.com.", "CNAME", "already has a A record set"},
+		{"A next to a CNAME", "alias.example.com.", "A", "already has a CNAME record set"},
+		{"CNAME at an empty name", "cdn.example.com.", "CNAME", ""},
+		{"A next to an A of another set", "www.example.com.", "A", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			fake.add("Z1",
+				testRecordSet("www.example.com.", "A", 300, "192.0.2.1"),
+				testRecordSet("alias.example.com.", "CNAME", 300, "www.example.com."),
+			)
+			c, _, _ := newTestCLI(t, fake)
+			value := "192.0.2.2"
+			if tt.typ == "CNAME" {
+				value = "lb.example.net."
+			}
+			rrs := testRecordSet(tt.rrs, tt.typ, 300, value)
+			err := c.checkCNAMEConflict("Z1", rrs)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("checkCNAMEConflict() = %v, want no error", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("checkCNAMEConflict() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCreateRefusesCNAMENextToA(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	fake.add("Z1", testRecordSet("www.example.com.", "A", 300, "192.0.2.1"))
+	c, _, _ := newTestCLI(t, fake)
+	if _, err := c.createChange("Z1", testRecordSet("www.example.com.", "CNAME", 300, "lb.example.net.")); err == nil {
+		t.Error("created a CNAME next to an A record set")
+	}
+	change, err := c.createChange("Z1", testRecordSet("cdn.example.com.", "CNAME", 300, "lb.example.net."))
+	if err != nil {
+		t.Fatalf("creating a CNAME at an empty name: %s", err)
+	}
+	if got := stringValue(change.Action); got != "CREATE" {
+		t.Errorf("action %s, want CREATE", got)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("%d ChangeResourceRecordSets calls while checking", len(fake.requests))
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// errNotConfirmed is returned when the operator declines a confirmation prompt
+var errNotConfirmed = errors.New("not confirmed")
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks the operator to confirm question on the terminal, -yes and -dry-run skip the prompt.
+// Without a terminal to ask on it refuses rather than assuming yes.
+func (c *cli) confirm(question string) error {
+	if c.assumeYes || c.dryRun {
+		return nil
+	}
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("%s needs confirmation, use -yes when not running interactively", question)
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errNotConfirmed
+}
+
+// parseTag splits a key=value tag as given to -auto-confirm-tag
+func parseTag(tag string) (key, value string, err error) {
+	kv := strings.SplitN(tag, "=", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return "", "", fmt.Errorf("tag %q is not key=value", tag)
+	}
+	return strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]), nil
+}
+
+// zoneHasTag reports whether the hosted zone is tagged key=value
+func (c *cli) zoneHasTag(zoneID, key, value string) (bool, error) {
+	resp, err := c.r53.ListTagsForResource(&route53.ListTagsForResourceRequest{
+		ResourceID:   aws.String(zoneID),
+		ResourceType: aws.String("hostedzone"),
+	})
+	if err != nil {
+		return false, err
+	}
+	if resp.ResourceTagSet == nil {
+		return false, nil
+	}
+	for _, tag := range resp.ResourceTagSet.Tags {
+		if stringValue(tag.Key) == key && stringValue(tag.Value) == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// confirmZone is confirm for changes to a zone, skipping the prompt for zones carrying the -auto-confirm-tag.
+// When the tags can't be read the prompt is kept.
+func (c *cli) confirmZone(zoneID, question string) error {
+	if c.autoConfirmKey != "" && !c.assumeYes && !c.dryRun {
+		tagged, err := c.zoneHasTag(zoneID, c.autoConfirmKey, c.autoConfirmValue)
+		switch {
+		case err != nil:
+			c.log.Printf("WARNING could not read the tags of zoneID=%s, asking for confirmation: %s\n", zoneID, err)
+		case tagged:
+			c.log.Printf("zoneID=%s is tagged %s=%s, not asking for confirmation\n", zoneID, c.autoConfirmKey, c.autoConfirmValue)
+			return nil
+		}
+	}
+	return c.confirm(question)
+}
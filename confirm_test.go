@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+)
+
+func TestConfirmZoneAutoConfirmTag(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      map[string]string
+		tagsErr   error
+		confirmed bool
+	}{
+		{"tagged", map[string]string{"env": "nonprod", "team": "dns"}, nil, true},
+		{"untagged", nil, nil, false},
+		{"tagged with another value", map[string]string{"env": "prod"}, nil, false},
+		// fails closed, the prompt is kept when the tags can't be read
+		{"tags unreadable", map[string]string{"env": "nonprod"}, aws.APIError{StatusCode: 403, Code: "AccessDenied"}, false},
+	}
+	// without an operator to answer, a prompt always ends unconfirmed
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	defer func(stdin, stderr *os.File) { os.Stdin, os.Stderr = stdin, stderr }(os.Stdin, os.Stderr)
+	os.Stdin, os.Stderr = devNull, devNull
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			fake.tags["Z1"] = tt.tags
+			fake.tagsErr = tt.tagsErr
+			c, logs, _ := newTestCLI(t, fake)
+			c.autoConfirmKey, c.autoConfirmValue = "env", "nonprod"
+			err := c.confirmZone("Z1", "apply 2 changes to zoneID=Z1?")
+			if confirmed := err == nil; confirmed != tt.confirmed {
+				t.Errorf("confirmZone() = %v, want confirmed %t", err, tt.confirmed)
+			}
+			if warned := strings.Contains(logs.String(), "could not read the tags"); warned != (tt.tagsErr != nil) {
+				t.Errorf("logged %q", logs.String())
+			}
+		})
+	}
+}
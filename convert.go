@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// maxWeight is the largest weight Route53 accepts for weighted records
+const maxWeight = 255
+
+// convertToWeighted replaces a simple record set with a weighted one holding the same values and TTL.
+// The routing policy is part of the record set's key so this needs a DELETE and CREATE, sent in one atomic batch.
+func (c *cli) convertToWeighted(zoneID string, simple route53.ResourceRecordSet, setID string, weight int64) error {
+	if simple.SetIdentifier != nil {
+		return fmt.Errorf("%s already has set identifier %s, only simple record sets can be converted", stringValue(simple.Name), *simple.SetIdentifier)
+	}
+	weighted := copyResourceRecordSet(simple)
+	weighted.SetIdentifier = aws.String(setID)
+	weighted.Weight = aws.Long(weight)
+	if err := c.validateRecordSet(weighted); err != nil {
+		return err
+	}
+
+	create, err := c.createChange(zoneID, weighted)
+	if err != nil {
+		return err
+	}
+	changes := []route53.Change{
+		{Action: aws.String("DELETE"), ResourceRecordSet: &simple},
+		*create,
+	}
+	if err := c.submitAtomic(zoneID, changes); err != nil {
+		return err
+	}
+	if !c.dryRun {
+		c.log.Printf("converted %s %s to weighted setIdentifier=%s weight=%d\n", stringValue(simple.Name), stringValue(simple.Type), setID, weight)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertToWeightedIsOneBatch(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	simple := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+	fake.add("Z1", simple)
+	c, _, _ := newTestCLI(t, fake)
+	// a -batch-size of 1 would otherwise send the DELETE and CREATE separately
+	c.batchSize = 1
+	if err := c.convertToWeighted("Z1", simple, "dc1", 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.requests) != 1 {
+		t.Fatalf("%d ChangeResourceRecordSets calls, want 1", len(fake.requests))
+	}
+	want := []string{"DELETE www.example.com. A", "CREATE www.example.com. A"}
+	if got := changesOf(fake.requests); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	if c.batchSize != 1 {
+		t.Errorf("batchSize = %d after converting, want it restored to 1", c.batchSize)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+)
+
+const (
+	imdsEndpoint = "http://169.254.169.254"
+	imdsTokenTTL = "21600"
+	// refresh instance credentials a little before AWS rotates them
+	imdsExpiryWindow = 5 * time.Minute
+)
+
+// resolveCreds returns the first credentials provider that can supply credentials,
+// trying the environment, then the shared credentials file, then the instance metadata service.
+// A named profile is used exclusively.
+func resolveCreds(profileName string, useIMDS bool) (aws.CredentialsProvider, error) {
+	if profileName != "" {
+		profile, err := aws.ProfileCreds("", profileName, 10*time.Minute)
+		if err == nil {
+			_, err = profile.Credentials()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %s", profileName, err)
+		}
+		return profile, nil
+	}
+
+	var errs []string
+
+	env, err := aws.EnvCreds()
+	if err == nil {
+		return env, nil
+	}
+	errs = append(errs, "env: "+err.Error())
+
+	profile, err := aws.ProfileCreds("", "", 10*time.Minute)
+	if err == nil {
+		if _, err = profile.Credentials(); err == nil {
+			return profile, nil
+		}
+	}
+	errs = append(errs, "profile: "+err.Error())
+
+	if useIMDS {
+		imds := &imdsCreds{client: &http.Client{Timeout: 2 * time.Second}}
+		if _, err = imds.Credentials(); err == nil {
+			return imds, nil
+		}
+		errs = append(errs, "instance metadata: "+err.Error())
+	}
+	return nil, fmt.Errorf("no credentials found (%s)", strings.Join(errs, "; "))
+}
+
+// credsFile is the JSON credentials file read by -creds-file, in the shape printed by aws sts and credential_process
+type credsFile struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+}
+
+// fileCreds loads static credentials from a JSON file, error messages never include the secret
+func fileCreds(path string) (aws.CredentialsProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var creds credsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	var missing []string
+	if creds.AccessKeyID == "" {
+		missing = append(missing, "AccessKeyId")
+	}
+	if creds.SecretAccessKey == "" {
+		missing = append(missing, "SecretAccessKey")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s is missing %s", path, strings.Join(missing, " and "))
+	}
+	return aws.Creds(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken), nil
+}
+
+// resolveRegion picks the AWS region: an explicit -region flag, then the AWS_REGION or
+// AWS_DEFAULT_REGION environment variables, then the profile's region in ~/.aws/config, then defaultRegion
+func resolveRegion(flagRegion string, flagSet bool, profileName string) string {
+	if flagSet {
+		return flagRegion
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region
+		}
+	}
+	if profileName == "" {
+		profileName = "default"
+	}
+	if region, err := profileRegion(awsConfigPath(), profileName); err == nil && region != "" {
+		return region
+	}
+	return defaultRegion
+}
+
+// awsConfigPath returns the location of the shared AWS config file
+func awsConfigPath() string {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".aws", "config")
+}
+
+// profileRegion reads the region setting of a profile from a shared AWS config file
+func profileRegion(path, profileName string) (string, error) {
+	settings, err := profileSettings(path, profileName)
+	return settings["region"], err
+}
+
+// profileSettings reads the key = value settings of a profile from a shared AWS config file.
+// Profiles are in [profile name] sections except the default profile, which is [default].
+func profileSettings(path, profileName string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	section := "profile " + profileName
+	if profileName == "default" {
+		section = "default"
+	}
+	settings := make(map[string]string)
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			inSection = name == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 {
+			key := strings.TrimSpace(kv[0])
+			if _, seen := settings[key]; !seen {
+				settings[key] = strings.TrimSpace(kv[1])
+			}
+		}
+	}
+	return settings, scanner.Err()
+}
+
+// imdsCreds fetches instance profile credentials from the EC2 instance metadata service.
+// IMDSv2 session tokens are used when available, falling back to IMDSv1.
+type imdsCreds struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	creds   *aws.Credentials
+	expires time.Time
+}
+
+// Credentials implements aws.CredentialsProvider, caching until shortly before the credentials expire
+func (p *imdsCreds) Credentials() (*aws.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds != nil && time.Now().Before(p.expires.Add(-imdsExpiryWindow)) {
+		return p.creds, nil
+	}
+
+	token := p.token()
+	roles, err := p.get(token, "/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return nil, fmt.Errorf("no instance profile role found")
+	}
+	body, err := p.get(token, "/latest/meta-data/iam/security-credentials/"+role)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Code            string
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return nil, fmt.Errorf("decoding instance credentials: %s", err)
+	}
+	if resp.Code != "" && resp.Code != "Success" {
+		return nil, fmt.Errorf("instance credentials unavailable: %s", resp.Code)
+	}
+	p.creds = &aws.Credentials{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SecurityToken:   resp.Token,
+	}
+	p.expires = resp.Expiration
+	return p.creds, nil
+}
+
+// token requests an IMDSv2 session token, returning "" if the service only speaks IMDSv1
+func (p *imdsCreds) token() string {
+	req, err := http.NewRequest("PUT", imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", imdsTokenTTL)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// get fetches a metadata path, sending the session token when there is one
+func (p *imdsCreds) get(token, path string) (string, error) {
+	req, err := http.NewRequest("GET", imdsEndpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata %s returned %s", path, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns of a CSV batch file, only name is required
+var csvColumns = []string{"action", "name", "type", "setid", "ttl", "values"}
+
+// parseCSVBatch reads batch entries from CSV with a header row naming the columns.
+// A row's values are separated by semicolons, e.g. 192.168.1.1;192.168.1.2, except inside quoted CAA values.
+// Only the CSV itself is checked here, like the other formats the entries are validated by checkEntries and runBatch
+func parseCSVBatch(r io.Reader) ([]batchEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %s", err)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if column == "value" {
+			column = "values"
+		}
+		if !validCSVColumn(column) {
+			return nil, fmt.Errorf("line 1: unknown column %q, columns are %s", column, strings.Join(csvColumns, ","))
+		}
+		if _, dup := columns[column]; dup {
+			return nil, fmt.Errorf("line 1: duplicate column %q", column)
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("line 1: a name column is required")
+	}
+
+	var entries []batchEntry
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		e := batchEntry{
+			Action: field("action"),
+			Name:   field("name"),
+			Type:   field("type"),
+			SetID:  field("setid"),
+		}
+		if ttl := field("ttl"); ttl != "" {
+			if e.TTL, err = strconv.ParseInt(ttl, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid ttl %q", line, ttl)
+			}
+		}
+		for _, v := range splitUnquoted(field("values"), ';', -1) {
+			if v = strings.TrimSpace(v); v != "" {
+				e.Values = append(e.Values, v)
+			}
+		}
+		entries = append(entries, e)
+	}
+}
+
+func validCSVColumn(column string) bool {
+	for _, c := range csvColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
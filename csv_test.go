@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVBatchMixedActions(t *testing.T) {
+	input := "Action, Name, Type, SetID, TTL, Value\n" +
+		"add,www.example.com,A,,,192.0.2.1;192.0.2.2\n" +
+		"del,www.example.com,a,,,192.0.2.3\n" +
+		"upsert,api.example.com,A,dc1,60,192.0.2.4\n" +
+		"delete,old.example.com,CNAME,,,\n" +
+		",mail.example.com,MX,,300,10 mx1.example.com.; 20 mx2.example.com.\n" +
+		"bogus,x.example.com,A,,,192.0.2.5\n"
+	entries, err := parseCSVBatch(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		entries[i].normalize()
+	}
+	want := []batchEntry{
+		{Action: "add", Name: "www.example.com.", Type: "A", Values: []string{"192.0.2.1", "192.0.2.2"}},
+		{Action: "del", Name: "www.example.com.", Type: "A", Values: []string{"192.0.2.3"}},
+		{Action: "upsert", Name: "api.example.com.", Type: "A", SetID: "dc1", TTL: 60, Values: []string{"192.0.2.4"}},
+		{Action: "delete", Name: "old.example.com.", Type: "CNAME"},
+		{Action: "upsert", Name: "mail.example.com.", Type: "MX", TTL: 300, Values: []string{"10 mx1.example.com.", "20 mx2.example.com."}},
+		// an invalid row is still returned, it's reported when the batch is checked or applied
+		{Action: "bogus", Name: "x.example.com.", Type: "A", Values: []string{"192.0.2.5"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestParseCSVBatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"unknown column", "name,colour\nwww.example.com,red\n", `line 1: unknown column "colour"`},
+		{"duplicate column", "name,value,values\nwww.example.com,192.0.2.1,192.0.2.2\n", `line 1: duplicate column "values"`},
+		{"no name column", "type,values\nA,192.0.2.1\n", "line 1: a name column is required"},
+		{"bad ttl", "name,ttl,values\nwww.example.com,300,192.0.2.1\napi.example.com,5m,192.0.2.2\n", `line 3: invalid ttl "5m"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCSVBatch(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunBatchContinuesPastBadCSVRow(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	c, _, _ := newTestCLI(t, fake)
+	input := "action,name,values\n" +
+		"upsert,a.example.com,192.0.2.1\n" +
+		"upsert,b.example.com,192.0.2.300\n" +
+		"upsert,c.example.com,192.0.2.3\n"
+	entries, err := parseCSVBatch(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		entries[i].normalize()
+	}
+	result := c.runBatch(entries, "Z1")
+	if result.applied != 2 || len(result.errs) != 1 {
+		t.Fatalf("%d applied and errors %v, want 2 applied and one error", result.applied, result.errs)
+	}
+	if !strings.HasPrefix(result.errs[0].Error(), "entry 2 (upsert b.example.com. A)") {
+		t.Errorf("error %q, want it about entry 2", result.errs[0])
+	}
+	want := []string{"UPSERT a.example.com. A", "UPSERT c.example.com. A"}
+	if got := changesOf(fake.requests); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+}
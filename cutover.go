@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// cutoverTarget is a name and the values cutover gives its record set
+type cutoverTarget struct {
+	name   string
+	values []string
+}
+
+// parseCutover reads name=value1,value2 arguments, checking the values are well formed for recordType
+func parseCutover(args []string, recordType string) ([]cutoverTarget, error) {
+	var targets []cutoverTarget
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%q is not a name=ipaddr1,ipaddr2 mapping", arg)
+		}
+		name := normalizeName(kv[0])
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%s is given more than once", name)
+		}
+		seen[strings.ToLower(name)] = true
+		values := splitValues([]string{kv[1]})
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s needs one or more values", name)
+		}
+		for i, v := range values {
+			if err := validateValue(recordType, v); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			values[i] = canonicalValue(recordType, v)
+		}
+		targets = append(targets, cutoverTarget{name: name, values: values})
+	}
+	return targets, nil
+}
+
+// cutover replaces the values of the record sets of several names, submitting the changes of each hosted zone
+// in a single ChangeResourceRecordSets, which Route53 applies atomically, so no name lags behind the others.
+// Every name is checked before anything is submitted.
+func (c *cli) cutover(zoneID, recordType, setID string, targets []cutoverTarget) (batchResult, error) {
+	var result batchResult
+	var zones []*zoneChanges
+	byZone := make(map[string]*zoneChanges)
+	for _, t := range targets {
+		nameZoneID := zoneID
+		if nameZoneID == "" {
+			var err error
+			if nameZoneID, err = c.zoneIDByName(t.name); err != nil {
+				result.fail(fmt.Errorf("%s: %w", t.name, zoneLookupError(err)))
+				continue
+			}
+		}
+		current, err := c.getResourceRecordSet(nameZoneID, t.name, recordType, setID)
+		if err != nil {
+			result.fail(fmt.Errorf("%s: %w", t.name, err))
+			continue
+		}
+		updated := copyResourceRecordSet(current)
+		updated.ResourceRecords = nil
+		for _, v := range t.values {
+			updated.ResourceRecords = append(updated.ResourceRecords, route53.ResourceRecord{Value: aws.String(v)})
+		}
+		change, err := c.upsertChange(current, updated)
+		if err != nil {
+			result.fail(fmt.Errorf("%s: %w", t.name, err))
+			continue
+		}
+		if change == nil {
+			result.skipped++
+			continue
+		}
+		c.backup(current)
+		zc, exists := byZone[nameZoneID]
+		if !exists {
+			zc = &zoneChanges{zoneID: nameZoneID}
+			byZone[nameZoneID] = zc
+			zones = append(zones, zc)
+		}
+		zc.changes = append(zc.changes, *change)
+	}
+	if len(result.errs) > 0 {
+		return result, fmt.Errorf("%d of %d names can't be cut over, nothing submitted", len(result.errs), len(targets))
+	}
+	if len(zones) > 1 {
+		c.log.Printf("WARNING the names are in %d hosted zones, each zone's changes are atomic but the zones are changed one after another\n", len(zones))
+	}
+	for _, zc := range zones {
+		err := c.submitAtomic(zc.zoneID, zc.changes)
+		result.zones++
+		if err != nil {
+			result.fail(fmt.Errorf("zoneID=%s: %w", zc.zoneID, err))
+			return result, err
+		}
+		result.applied += len(zc.changes)
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+)
+
+func cutoverFake() *fakeRoute53 {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com.", "Z2": "example.org."})
+	fake.add("Z1",
+		testRecordSet("www.example.com.", "A", 60, "192.0.2.1"),
+		testRecordSet("api.example.com.", "A", 60, "192.0.2.2"),
+	)
+	fake.add("Z2", testRecordSet("www.example.org.", "A", 60, "192.0.2.3"))
+	return fake
+}
+
+func TestCutoverOneCallPerZone(t *testing.T) {
+	fake := cutoverFake()
+	c, _, _ := newTestCLI(t, fake)
+	// a -batch-size of 1 would otherwise split the changes of example.com
+	c.batchSize = 1
+	targets, err := parseCutover([]string{
+		"www.example.com=198.51.100.1",
+		"api.example.com=198.51.100.2,198.51.100.3",
+		"www.example.org=198.51.100.4",
+	}, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.cutover("", "A", "", targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.applied != 3 || result.zones != 2 {
+		t.Errorf("applied %d changes in %d zones, want 3 in 2", result.applied, result.zones)
+	}
+	if c.batchSize != 1 {
+		t.Errorf("-batch-size is %d after the cutover, want it kept at 1", c.batchSize)
+	}
+	calls := make(map[string]int)
+	for _, req := range fake.requests {
+		calls[stringValue(req.HostedZoneID)]++
+	}
+	if len(fake.requests) != 2 || calls["Z1"] != 1 || calls["Z2"] != 1 {
+		t.Errorf("ChangeResourceRecordSets calls per zone %v, want one each for Z1 and Z2", calls)
+	}
+	if n := len(fake.requests[0].ChangeBatch.Changes); n != 2 {
+		t.Errorf("first batch has %d changes, want both example.com names", n)
+	}
+}
+
+func TestCutoverSubmitsNothingWhenAnyNameIsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"missing record set", []string{"www.example.com=198.51.100.1", "missing.example.org=198.51.100.2"}},
+		{"unknown zone", []string{"www.example.com=198.51.100.1", "www.example.net=198.51.100.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := cutoverFake()
+			c, _, _ := newTestCLI(t, fake)
+			targets, err := parseCutover(tt.args, "A")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := c.cutover("", "A", "", targets)
+			if err == nil {
+				t.Fatal("cutover() succeeded, want an error")
+			}
+			if len(result.errs) != 1 {
+				t.Errorf("%d errors %v, want 1", len(result.errs), result.errs)
+			}
+			if len(fake.requests) != 0 {
+				t.Errorf("%d ChangeResourceRecordSets calls, want none", len(fake.requests))
+			}
+		})
+	}
+}
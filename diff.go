@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// recordSetDiff describes how a live record set differs from the desired state in a batch entry
+type recordSetDiff struct {
+	entry batchEntry
+	// create is set when the record set doesn't exist yet, remove when it exists but shouldn't
+	create  bool
+	remove  bool
+	added   []string
+	removed []string
+	oldTTL  int64
+	newTTL  int64
+	// routing is set when the routing policy or health check differs, which the values and TTL don't show
+	routing bool
+	// zoneID, live and desired are what the diff was computed from, nil when the record set is missing or should be
+	zoneID  string
+	live    *route53.ResourceRecordSet
+	desired *route53.ResourceRecordSet
+}
+
+// drifted reports whether applying the entry would change anything
+func (d recordSetDiff) drifted() bool {
+	return d.create || d.remove || len(d.added) > 0 || len(d.removed) > 0 || d.oldTTL != d.newTTL || d.routing
+}
+
+// desiredRecordSet returns what the entry would make of the live record set, live is nil when it doesn't exist.
+// The second result is false when the entry removes the record set.
+func (c *cli) desiredRecordSet(e batchEntry, live *route53.ResourceRecordSet) (route53.ResourceRecordSet, bool) {
+	switch e.Action {
+	case "delete":
+		return route53.ResourceRecordSet{}, false
+	case "add", "del":
+		if live == nil {
+			return e.recordSet(), true
+		}
+		if e.Action == "add" {
+			return c.withValuesAdded(*live, e.Values...), true
+		}
+		return c.withValuesRemoved(*live, e.Values...), true
+	}
+	desired := e.recordSet()
+	if live != nil && e.TTL == 0 {
+		desired.TTL = live.TTL
+	}
+	return desired, true
+}
+
+// diffRecordSets compares the live record set (nil when missing) with the desired one (nil when it should be absent).
+// It drifted exactly when the canonical forms differ, the same comparison -only-if-changed and -output=hash use.
+func diffRecordSets(e batchEntry, live, desired *route53.ResourceRecordSet) recordSetDiff {
+	d := recordSetDiff{entry: e, live: live, desired: desired}
+	switch {
+	case live == nil && desired == nil:
+		return d
+	case live == nil:
+		d.create = true
+		d.added = recordValues(*desired)
+		return d
+	case desired == nil:
+		d.remove = true
+		d.removed = recordValues(*live)
+		return d
+	}
+
+	liveValues := make(map[string]struct{})
+	for _, v := range recordValues(*live) {
+		liveValues[v] = struct{}{}
+	}
+	desiredValues := make(map[string]struct{})
+	for _, v := range recordValues(*desired) {
+		desiredValues[v] = struct{}{}
+		if _, exists := liveValues[v]; !exists {
+			d.added = append(d.added, v)
+		}
+	}
+	for _, v := range recordValues(*live) {
+		if _, exists := desiredValues[v]; !exists {
+			d.removed = append(d.removed, v)
+		}
+	}
+	if live.TTL != nil {
+		d.oldTTL = *live.TTL
+	}
+	if desired.TTL != nil {
+		d.newTTL = *desired.TTL
+	}
+	d.routing = !sameRouting(*live, *desired)
+	return d
+}
+
+// sameRouting reports whether two record sets of the same name have the same routing policy and health check,
+// ignoring values and TTL. Names are compared by the caller, Route53 may return them escaped.
+func sameRouting(a, b route53.ResourceRecordSet) bool {
+	a.ResourceRecords, a.TTL = nil, nil
+	b.ResourceRecords, b.TTL, b.Name = nil, nil, a.Name
+	return sameResourceRecordSet(a, b)
+}
+
+// describeRouting lists the routing fields of a record set, e.g. "weight=10 healthcheck=hc1", "simple" when it has none
+func describeRouting(rrs route53.ResourceRecordSet) string {
+	var fields []string
+	if rrs.Weight != nil {
+		fields = append(fields, "weight="+longValue(rrs.Weight))
+	}
+	if isMultiValue(rrs) {
+		fields = append(fields, "multivalue")
+	}
+	if rrs.Region != nil {
+		fields = append(fields, "region="+*rrs.Region)
+	}
+	if rrs.Failover != nil {
+		fields = append(fields, "failover="+*rrs.Failover)
+	}
+	if geo := rrs.GeoLocation; geo != nil {
+		fields = append(fields, "geolocation="+strings.Trim(strings.Join([]string{stringValue(geo.ContinentCode), stringValue(geo.CountryCode), stringValue(geo.SubdivisionCode)}, "/"), "/"))
+	}
+	if rrs.HealthCheckID != nil {
+		fields = append(fields, "healthcheck="+*rrs.HealthCheckID)
+	}
+	if len(fields) == 0 {
+		return "simple"
+	}
+	return strings.Join(fields, " ")
+}
+
+// diffEntries compares every entry with the live record sets without changing anything
+func (c *cli) diffEntries(entries []batchEntry, zoneID string) ([]recordSetDiff, error) {
+	var diffs []recordSetDiff
+	for i, e := range entries {
+		if err := e.validate(); err != nil {
+			return nil, fmt.Errorf("entry %d (%s): %s", i+1, e, err)
+		}
+		entryZoneID := zoneID
+		if entryZoneID == "" {
+			var err error
+			if entryZoneID, err = c.zoneIDByName(e.Name); err != nil {
+				return nil, fmt.Errorf("entry %d (%s): %s", i+1, e, zoneLookupError(err))
+			}
+		}
+		var live *route53.ResourceRecordSet
+		rrs, err := c.getResourceRecordSet(entryZoneID, e.Name, e.Type, e.SetID)
+		switch {
+		case err == nil:
+			live = &rrs
+		case !isNotFound(err):
+			return nil, fmt.Errorf("entry %d (%s): %s", i+1, e, err)
+		}
+		var desired *route53.ResourceRecordSet
+		if d, exists := c.desiredRecordSet(e, live); exists {
+			desired = &d
+		}
+		d := diffRecordSets(e, live, desired)
+		d.zoneID = entryZoneID
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// printDiffs writes the drifted record sets in a plan-like format, returning how many drifted
+func printDiffs(w io.Writer, diffs []recordSetDiff) int {
+	drifted := 0
+	for _, d := range diffs {
+		if !d.drifted() {
+			continue
+		}
+		drifted++
+		id := fmt.Sprintf("%s %s", d.entry.Name, d.entry.Type)
+		if d.entry.SetID != "" {
+			id += " setid=" + d.entry.SetID
+		}
+		switch {
+		case d.create:
+			fmt.Fprintf(w, "+ %s (create)\n", id)
+		case d.remove:
+			fmt.Fprintf(w, "- %s (delete)\n", id)
+		default:
+			fmt.Fprintf(w, "~ %s\n", id)
+		}
+		for _, v := range d.added {
+			fmt.Fprintf(w, "    + %s\n", v)
+		}
+		for _, v := range d.removed {
+			fmt.Fprintf(w, "    - %s\n", v)
+		}
+		if !d.create && !d.remove && d.oldTTL != d.newTTL {
+			fmt.Fprintf(w, "    ttl %d -> %d\n", d.oldTTL, d.newTTL)
+		}
+		if d.routing {
+			fmt.Fprintf(w, "    routing %s -> %s\n", describeRouting(*d.live), describeRouting(*d.desired))
+		}
+	}
+	if drifted == 0 {
+		fmt.Fprintln(w, "no drift")
+	}
+	return drifted
+}
+
+// change returns the change reconciling the live record set with the desired one, nil when it hasn't drifted
+func (d recordSetDiff) change() *route53.Change {
+	switch {
+	case !d.drifted():
+		return nil
+	case d.create:
+		return &route53.Change{Action: aws.String("CREATE"), ResourceRecordSet: d.desired}
+	case d.remove:
+		return &route53.Change{Action: aws.String("DELETE"), ResourceRecordSet: d.live}
+	}
+	return &route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: d.desired}
+}
+
+// printDiffBatches writes the changes reconciling the drifted record sets as AWS CLI change batches, one per zone,
+// returning how many drifted
+func (c *cli) printDiffBatches(w io.Writer, diffs []recordSetDiff) (int, error) {
+	var zones []string
+	byZone := make(map[string][]route53.Change)
+	drifted := 0
+	for _, d := range diffs {
+		change := d.change()
+		if change == nil {
+			continue
+		}
+		drifted++
+		if _, exists := byZone[d.zoneID]; !exists {
+			zones = append(zones, d.zoneID)
+		}
+		byZone[d.zoneID] = append(byZone[d.zoneID], *change)
+	}
+	for _, zoneID := range zones {
+		if len(zones) > 1 {
+			c.log.Printf("change batch for zoneID=%s\n", zoneID)
+		}
+		if err := printAWSCLIBatch(w, route53.ChangeBatch{Changes: byZone[zoneID]}); err != nil {
+			return drifted, err
+		}
+	}
+	if drifted == 0 {
+		c.log.Println("no drift")
+	}
+	return drifted, nil
+}
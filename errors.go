@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit codes, each error code maps to one so scripts can branch on either
+const (
+	exitError        = 1
+	exitDrift        = 2
+	exitNotFound     = 3
+	exitAccessDenied = 4
+	exitTimeout      = 5
+	exitInterrupted  = 130
+)
+
+// Error codes reported in -output=json error objects
+const (
+	codeError        = "error"
+	codeUsage        = "usage"
+	codeNotFound     = "not_found"
+	codeAccessDenied = "access_denied"
+	codeTimeout      = "timeout"
+	codeInterrupted  = "interrupted"
+)
+
+var exitCodes = map[string]int{
+	codeError:        exitError,
+	codeUsage:        exitError,
+	codeNotFound:     exitNotFound,
+	codeAccessDenied: exitAccessDenied,
+	codeTimeout:      exitTimeout,
+	codeInterrupted:  exitInterrupted,
+}
+
+// jsonErrors makes fatal errors print as JSON objects on stdout, it is set by -output=json
+var jsonErrors bool
+
+// codedError attaches an error code to an error that can't be classified from its type
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e codedError) Error() string { return e.err.Error() }
+func (e codedError) Unwrap() error { return e.err }
+
+// errorCode classifies err for the error output and exit code
+func errorCode(err error) string {
+	var coded codedError
+	var notFound recordSetNotFoundError
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &notFound):
+		return codeNotFound
+	case errors.Is(err, errTimeout):
+		return codeTimeout
+	case errors.Is(err, errInterrupted):
+		return codeInterrupted
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if isAccessDenied(e) {
+			return codeAccessDenied
+		}
+	}
+	return codeError
+}
+
+// errorObject is the JSON form of a fatal error
+type errorObject struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// exitWithError reports a fatal error, as a JSON object on stdout with -output=json and
+// as an ERROR line on stderr otherwise, and exits with the code's exit status
+func exitWithError(code, message string) {
+	if jsonErrors {
+		json.NewEncoder(os.Stdout).Encode(errorObject{Error: message, Code: code})
+	} else {
+		fmt.Fprintln(os.Stderr, "ERROR", message)
+	}
+	os.Exit(exitCodes[code])
+}
+
+// fatal reports err and exits, see exitWithError
+func (c *cli) fatal(err error) {
+	c.pushMetrics()
+	if !jsonErrors {
+		c.log.Println("ERROR", err)
+		os.Exit(exitCodes[errorCode(err)])
+	}
+	exitWithError(errorCode(err), err.Error())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+)
+
+func TestErrorCode(t *testing.T) {
+	accessDenied := aws.APIError{StatusCode: 403, Code: "AccessDenied", Message: "not authorized"}
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"plain", errors.New("boom"), codeError},
+		{"coded", codedError{codeUsage, errors.New("bad flag")}, codeUsage},
+		{"wrapped coded", fmt.Errorf("running %w", codedError{codeNotFound, errors.New("gone")}), codeNotFound},
+		{"record set not found", fmt.Errorf("getting %w", recordSetNotFoundError{zoneID: "Z1", name: "www.example.com."}), codeNotFound},
+		{"timeout", fmt.Errorf("listing %w", errTimeout), codeTimeout},
+		{"interrupted", errInterrupted, codeInterrupted},
+		{"access denied", accessDenied, codeAccessDenied},
+		{"access denied pointer", &aws.APIError{Code: "AccessDeniedException"}, codeAccessDenied},
+		{"wrapped access denied", fmt.Errorf("listing hosted zones %w", accessDenied), codeAccessDenied},
+		{"other API error", aws.APIError{StatusCode: 400, Code: "InvalidChangeBatch"}, codeError},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.code {
+			t.Errorf("%s: errorCode(%v) = %s, want %s", tt.name, tt.err, got, tt.code)
+		}
+	}
+}
+
+func TestZoneLookupError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code string
+		msg  string
+	}{
+		{"access denied", aws.APIError{StatusCode: 403, Code: "AccessDenied"}, codeAccessDenied, "route53:ListHostedZones permission is required"},
+		{"wrapped access denied", fmt.Errorf("listing %w", &aws.APIError{Code: "AccessDenied"}), codeAccessDenied, "pass -zoneid to skip the lookup"},
+		{"not found", errors.New("zone example.com. not found"), codeError, "getting zoneid zone example.com. not found"},
+		{"timeout", errTimeout, codeTimeout, "getting zoneid timed out"},
+	}
+	for _, tt := range tests {
+		err := zoneLookupError(tt.err)
+		if got := errorCode(err); got != tt.code {
+			t.Errorf("%s: errorCode = %s, want %s", tt.name, got, tt.code)
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: zoneLookupError = %q, want it to contain %q", tt.name, err, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// routingPolicy names the routing policy of a record set for explanations
+func routingPolicy(rrs route53.ResourceRecordSet) string {
+	switch {
+	case rrs.Weight != nil:
+		return "weighted"
+	case isMultiValue(rrs):
+		return "multivalue"
+	case rrs.Region != nil:
+		return "latency"
+	case rrs.Failover != nil:
+		return "failover"
+	case rrs.GeoLocation != nil:
+		return "geolocation"
+	}
+	return "simple"
+}
+
+// describeRecordSet names a record set in plain English, e.g. "the weighted A record www.example.com. (setid=dc1, weight=10)"
+func describeRecordSet(rrs route53.ResourceRecordSet) string {
+	s := fmt.Sprintf("the %s %s record %s", routingPolicy(rrs), stringValue(rrs.Type), stringValue(rrs.Name))
+	var details []string
+	if rrs.SetIdentifier != nil {
+		details = append(details, "setid="+*rrs.SetIdentifier)
+	}
+	if rrs.Weight != nil {
+		details = append(details, "weight="+longValue(rrs.Weight))
+	}
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+// describeZone names a zone by name and ID when the name is known, pointing out private zones
+func describeZone(zone zoneRef) string {
+	kind := "zone"
+	if zone.private {
+		kind = "private zone"
+	}
+	if zone.name == "" {
+		return kind + " " + zone.id
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, zone.name, zone.id)
+}
+
+// countIPs formats a list of IPs like "2 IPs (1.2.3.4, 5.6.7.8)"
+func countIPs(ips []string) string {
+	noun := "IPs"
+	if len(ips) == 1 {
+		noun = "IP"
+	}
+	return fmt.Sprintf("%d %s (%s)", len(ips), noun, strings.Join(ips, ", "))
+}
+
+// describeTTLChange tells how -ttl changes the TTL of rrs, empty when it doesn't
+func describeTTLChange(rrs route53.ResourceRecordSet, ttl int64) string {
+	if ttl <= 0 || rrs.AliasTarget != nil || (rrs.TTL != nil && *rrs.TTL == ttl) {
+		return ""
+	}
+	return fmt.Sprintf(" Its TTL will change from %s to %d.", longValue(rrs.TTL), ttl)
+}
+
+// explain restates what an action is about to do to rrs, so operators can catch a wrong flag before it is applied.
+// ttl is the -ttl set along with the values, 0 when the TTL is kept.
+func explain(action string, rrs route53.ResourceRecordSet, zone zoneRef, values []string, ttl int64) string {
+	target := describeRecordSet(rrs)
+	where := describeZone(zone)
+	switch action {
+	case "add":
+		return fmt.Sprintf("This will add %s to %s in %s.", countIPs(values), target, where) + describeTTLChange(rrs, ttl)
+	case "del":
+		return fmt.Sprintf("This will remove %s from %s in %s.", countIPs(values), target, where) + describeTTLChange(rrs, ttl)
+	case "prune":
+		return fmt.Sprintf("This will remove every value but %s from %s in %s.", countIPs(values), target, where) + describeTTLChange(rrs, ttl)
+	case "swap":
+		return fmt.Sprintf("This will replace %s in %s in %s.", strings.Join(values, ", "), target, where) + describeTTLChange(rrs, ttl)
+	case "update":
+		return fmt.Sprintf("This will update the settings of %s in %s.", target, where) + describeTTLChange(rrs, ttl)
+	case "convert":
+		return fmt.Sprintf("This will replace %s with a weighted record (%s) in %s, keeping its values and TTL.", target, strings.Join(values, ", "), where)
+	case "simplify":
+		return fmt.Sprintf("This will replace %s with a simple record without set identifier or routing policy in %s, keeping its values and TTL.", target, where)
+	case "from-dns":
+		return fmt.Sprintf("This will create %s in %s from the A records it currently resolves to.", target, where)
+	}
+	return fmt.Sprintf("This will %s %s in %s.", action, target, where)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+func TestExplainTTLChange(t *testing.T) {
+	rrs := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+	zone := zoneRef{name: "example.com.", id: "Z1"}
+	alias := route53.ResourceRecordSet{
+		Name:        aws.String("www.example.com."),
+		Type:        aws.String("A"),
+		AliasTarget: &route53.AliasTarget{DNSName: aws.String("lb.example.net."), HostedZoneID: aws.String("Z2")},
+	}
+	tests := []struct {
+		action string
+		rrs    route53.ResourceRecordSet
+		values []string
+		ttl    int64
+		want   string
+	}{
+		{"update", rrs, nil, 60,
+			"This will update the settings of the simple A record www.example.com. in zone example.com. (Z1). Its TTL will change from 300 to 60."},
+		{"add", rrs, []string{"192.0.2.2"}, 60,
+			"This will add 1 IP (192.0.2.2) to the simple A record www.example.com. in zone example.com. (Z1). Its TTL will change from 300 to 60."},
+		{"del", rrs, []string{"192.0.2.1"}, 3600,
+			"This will remove 1 IP (192.0.2.1) from the simple A record www.example.com. in zone example.com. (Z1). Its TTL will change from 300 to 3600."},
+		{"swap", rrs, []string{"192.0.2.1 with 192.0.2.5"}, 60,
+			"This will replace 192.0.2.1 with 192.0.2.5 in the simple A record www.example.com. in zone example.com. (Z1). Its TTL will change from 300 to 60."},
+		// without -ttl, or with the TTL it already has, the TTL isn't mentioned
+		{"add", rrs, []string{"192.0.2.2"}, 0,
+			"This will add 1 IP (192.0.2.2) to the simple A record www.example.com. in zone example.com. (Z1)."},
+		{"add", rrs, []string{"192.0.2.2"}, 300,
+			"This will add 1 IP (192.0.2.2) to the simple A record www.example.com. in zone example.com. (Z1)."},
+		// alias record sets have no TTL of their own
+		{"update", alias, nil, 60,
+			"This will update the settings of the simple A record www.example.com. in zone example.com. (Z1)."},
+	}
+	for _, tt := range tests {
+		if got := explain(tt.action, tt.rrs, zone, tt.values, tt.ttl); got != tt.want {
+			t.Errorf("explain(%s, ttl=%d) =\n%s\nwant\n%s", tt.action, tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestExplainAddDel(t *testing.T) {
+	simple := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+	weighted := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+	weighted.SetIdentifier = aws.String("dc1")
+	weighted.Weight = aws.Long(10)
+	tests := []struct {
+		name   string
+		action string
+		rrs    route53.ResourceRecordSet
+		zone   zoneRef
+		values []string
+		want   string
+	}{
+		{"add one", "add", simple, zoneRef{name: "example.com.", id: "Z1"}, []string{"192.0.2.2"},
+			"This will add 1 IP (192.0.2.2) to the simple A record www.example.com. in zone example.com. (Z1)."},
+		{"add several", "add", simple, zoneRef{name: "example.com.", id: "Z1"}, []string{"192.0.2.2", "192.0.2.3"},
+			"This will add 2 IPs (192.0.2.2, 192.0.2.3) to the simple A record www.example.com. in zone example.com. (Z1)."},
+		{"add weighted", "add", weighted, zoneRef{name: "example.com.", id: "Z1"}, []string{"192.0.2.2"},
+			"This will add 1 IP (192.0.2.2) to the weighted A record www.example.com. (setid=dc1, weight=10) in zone example.com. (Z1)."},
+		{"del one", "del", simple, zoneRef{name: "example.com.", id: "Z1"}, []string{"192.0.2.1"},
+			"This will remove 1 IP (192.0.2.1) from the simple A record www.example.com. in zone example.com. (Z1)."},
+		{"del several", "del", simple, zoneRef{name: "example.com.", id: "Z1"}, []string{"192.0.2.1", "192.0.2.2"},
+			"This will remove 2 IPs (192.0.2.1, 192.0.2.2) from the simple A record www.example.com. in zone example.com. (Z1)."},
+		{"del private zone", "del", simple, zoneRef{name: "example.com.", id: "Z1", private: true}, []string{"192.0.2.1"},
+			"This will remove 1 IP (192.0.2.1) from the simple A record www.example.com. in private zone example.com. (Z1)."},
+		{"del zone id only", "del", simple, zoneRef{id: "Z1"}, []string{"192.0.2.1"},
+			"This will remove 1 IP (192.0.2.1) from the simple A record www.example.com. in zone Z1."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explain(tt.action, tt.rrs, tt.zone, tt.values, 0); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
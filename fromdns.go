@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+const resolverTimeout = 5 * time.Second
+
+// dnsResolver returns a resolver querying the DNS server given as host or host:port,
+// an empty server uses the system resolver
+func dnsResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// lookupValues resolves the values of the A, AAAA or CNAME record name currently has, sorted
+func lookupValues(resolver, recordType, name string) ([]string, error) {
+	r := dnsResolver(resolver)
+	ctx, cancel := context.WithTimeout(context.Background(), resolverTimeout)
+	defer cancel()
+	if recordType == "CNAME" {
+		target, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{target}, nil
+	}
+	network := "ip4"
+	if recordType == "AAAA" {
+		network = "ip6"
+	}
+	addrs, err := r.LookupIP(ctx, network, name)
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ips = append(ips, addr.String())
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+// resolveValues looks up the current values of a record, tests replace it to avoid real DNS queries
+var resolveValues = lookupValues
+
+// createFromDNS creates rrs populated with the values of its type its name currently resolves to,
+// so an externally hosted record can be moved into Route53
+func (c *cli) createFromDNS(zoneID string, rrs route53.ResourceRecordSet, resolver string) error {
+	recordType := stringValue(rrs.Type)
+	values, err := resolveValues(resolver, recordType, *rrs.Name)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %s", *rrs.Name, err)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("%s did not resolve to any %s records", *rrs.Name, recordType)
+	}
+	for _, v := range values {
+		if err := validateValue(recordType, v); err != nil {
+			return fmt.Errorf("resolving %s: %s", *rrs.Name, err)
+		}
+		rrs.ResourceRecords = append(rrs.ResourceRecords, route53.ResourceRecord{Value: aws.String(v)})
+	}
+	c.log.Printf("resolved %s %s to %v\n", *rrs.Name, recordType, values)
+
+	if err := c.applyOverrides(&rrs); err != nil {
+		return err
+	}
+	if err := c.validateRecordSet(rrs); err != nil {
+		return err
+	}
+	change, err := c.createChange(zoneID, rrs)
+	if err != nil {
+		return err
+	}
+	if err := c.submitChanges(zoneID, []route53.Change{*change}); err != nil {
+		return err
+	}
+	if !c.dryRun {
+		c.log.Printf("created %s with %d records\n", *rrs.Name, len(values))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateFromDNSResolvesRecordType(t *testing.T) {
+	answers := map[string][]string{
+		"A":     {"192.0.2.1", "192.0.2.2"},
+		"AAAA":  {"2001:db8::1"},
+		"CNAME": {"lb.example.net."},
+	}
+	tests := []struct {
+		recordType string
+		answers    map[string][]string
+		values     []string
+		err        string
+	}{
+		{"A", answers, []string{"192.0.2.1", "192.0.2.2"}, ""},
+		{"AAAA", answers, []string{"2001:db8::1"}, ""},
+		{"CNAME", answers, []string{"lb.example.net."}, ""},
+		// a resolver answering AAAA queries with IPv4 addresses is refused rather than creating a broken record set
+		{"AAAA", map[string][]string{"AAAA": {"192.0.2.1"}}, nil, "not an IPv6 address"},
+		{"AAAA", map[string][]string{}, nil, "did not resolve to any AAAA records"},
+	}
+	defer func(f func(string, string, string) ([]string, error)) { resolveValues = f }(resolveValues)
+	for _, tt := range tests {
+		t.Run(tt.recordType, func(t *testing.T) {
+			var queried []string
+			resolveValues = func(resolver, recordType, name string) ([]string, error) {
+				queried = append(queried, resolver+" "+recordType+" "+name)
+				return tt.answers[recordType], nil
+			}
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			c, _, _ := newTestCLI(t, fake)
+			rrs := newResourceRecordSet("www.example.com.", tt.recordType, "", 300)
+			err := c.createFromDNS("Z1", rrs, "192.0.2.53")
+			if want := []string{"192.0.2.53 " + tt.recordType + " www.example.com."}; !reflect.DeepEqual(queried, want) {
+				t.Errorf("resolved %v, want %v", queried, want)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want one containing %q", err, tt.err)
+				}
+				if len(fake.requests) != 0 {
+					t.Errorf("%d ChangeResourceRecordSets calls after a failed lookup", len(fake.requests))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"CREATE www.example.com. " + tt.recordType}; !reflect.DeepEqual(changesOf(fake.requests), want) {
+				t.Fatalf("changes = %v, want %v", changesOf(fake.requests), want)
+			}
+			if got := recordValues(*fake.requests[0].ChangeBatch.Changes[0].ResourceRecordSet); !reflect.DeepEqual(got, tt.values) {
+				t.Errorf("created with %q, want %q", got, tt.values)
+			}
+		})
+	}
+}
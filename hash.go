@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// outputHash prints a content hash per record set, for external systems to detect changes cheaply
+const outputHash = "hash"
+
+// canonicalRecordSet renders what a record set answers with as text independent of the order of its values,
+// two record sets are the same when their canonical forms are equal
+func canonicalRecordSet(rrs route53.ResourceRecordSet) string {
+	lines := []string{
+		"name " + stringValue(rrs.Name),
+		"type " + stringValue(rrs.Type),
+		"setid " + stringValue(rrs.SetIdentifier),
+		"ttl " + longValue(rrs.TTL),
+		"weight " + longValue(rrs.Weight),
+		"region " + stringValue(rrs.Region),
+		"failover " + stringValue(rrs.Failover),
+		"multivalue " + strconv.FormatBool(isMultiValue(rrs)),
+		"healthcheck " + stringValue(rrs.HealthCheckID),
+	}
+	if geo := rrs.GeoLocation; geo != nil {
+		lines = append(lines, "geo "+strings.Join([]string{stringValue(geo.ContinentCode), stringValue(geo.CountryCode), stringValue(geo.SubdivisionCode)}, "/"))
+	}
+	if alias := rrs.AliasTarget; alias != nil {
+		lines = append(lines, fmt.Sprintf("alias %s %s evaluate=%t",
+			strings.ToLower(unescapeName(normalizeName(stringValue(alias.DNSName)))), stringValue(alias.HostedZoneID), evaluatesTargetHealth(rrs)))
+	}
+	var values []string
+	for _, rr := range rrs.ResourceRecords {
+		values = append(values, "value "+stringValue(rr.Value))
+	}
+	sort.Strings(values)
+	return strings.Join(append(lines, values...), "\n")
+}
+
+// recordSetHash is the hex sha256 of the canonical form of rrs
+func recordSetHash(rrs route53.ResourceRecordSet) string {
+	sum := sha256.Sum256([]byte(canonicalRecordSet(rrs)))
+	return hex.EncodeToString(sum[:])
+}
+
+// printHashes writes the content hash of each record set followed by its name, type and set identifier
+func printHashes(w io.Writer, sets []route53.ResourceRecordSet) error {
+	for _, rrs := range sets {
+		id := stringValue(rrs.Name) + " " + stringValue(rrs.Type)
+		if rrs.SetIdentifier != nil {
+			id += " " + *rrs.SetIdentifier
+		}
+		if _, err := fmt.Fprintf(w, "%s  %s\n", recordSetHash(rrs), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+func TestRecordSetHashIgnoresValueOrder(t *testing.T) {
+	a := testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	b := testRecordSet("www.example.com.", "A", 300, "192.0.2.3", "192.0.2.1", "192.0.2.2")
+	if recordSetHash(a) != recordSetHash(b) {
+		t.Errorf("hash changed when the values were reordered:\n%s\n%s", canonicalRecordSet(a), canonicalRecordSet(b))
+	}
+	var bufA, bufB bytes.Buffer
+	if err := printHashes(&bufA, []route53.ResourceRecordSet{a}); err != nil {
+		t.Fatal(err)
+	}
+	if err := printHashes(&bufB, []route53.ResourceRecordSet{b}); err != nil {
+		t.Fatal(err)
+	}
+	if bufA.String() != bufB.String() || !strings.HasSuffix(bufA.String(), "  www.example.com. A\n") {
+		t.Errorf("-output=hash wrote %q and %q", bufA.String(), bufB.String())
+	}
+}
+
+// TestRecordSetHashMatchesDiff checks a hash changes exactly when diff and -only-if-changed see a change
+func TestRecordSetHashMatchesDiff(t *testing.T) {
+	live := testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2")
+	live.SetIdentifier = aws.String("dc1")
+	live.Weight = aws.Long(10)
+	live.HealthCheckID = aws.String("hc1")
+	tests := []struct {
+		name   string
+		change func(rrs *route53.ResourceRecordSet)
+		same   bool
+	}{
+		{"unchanged", func(rrs *route53.ResourceRecordSet) {}, true},
+		{"values reordered", func(rrs *route53.ResourceRecordSet) {
+			rrs.ResourceRecords = []route53.ResourceRecord{rrs.ResourceRecords[1], rrs.ResourceRecords[0]}
+		}, true},
+		{"value added", func(rrs *route53.ResourceRecordSet) {
+			rrs.ResourceRecords = append(rrs.ResourceRecords, route53.ResourceRecord{Value: aws.String("192.0.2.3")})
+		}, false},
+		{"value replaced", func(rrs *route53.ResourceRecordSet) {
+			rrs.ResourceRecords = []route53.ResourceRecord{{Value: aws.String("192.0.2.1")}, {Value: aws.String("192.0.2.9")}}
+		}, false},
+		{"ttl", func(rrs *route53.ResourceRecordSet) { rrs.TTL = aws.Long(60) }, false},
+		{"weight", func(rrs *route53.ResourceRecordSet) { rrs.Weight = aws.Long(20) }, false},
+		{"health check", func(rrs *route53.ResourceRecordSet) { rrs.HealthCheckID = nil }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := live
+			desired.ResourceRecords = append([]route53.ResourceRecord(nil), live.ResourceRecords...)
+			tt.change(&desired)
+			sameHash := recordSetHash(live) == recordSetHash(desired)
+			drifted := diffRecordSets(batchEntry{Name: "www.example.com.", Type: "A"}, &live, &desired).drifted()
+			same := sameResourceRecordSet(live, desired)
+			if sameHash != tt.same || drifted == tt.same || same != tt.same {
+				t.Errorf("same hash %t, diff drifted %t, -only-if-changed same %t, want the record sets the same: %t", sameHash, drifted, same, tt.same)
+			}
+		})
+	}
+}
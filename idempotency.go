@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+const (
+	recentChangesFile    = "recent_changes.json"
+	defaultDedupWindow   = 5 * time.Minute
+	stateDirPermissions  = 0700
+	stateFilePermissions = 0600
+)
+
+// stateDir returns the directory holding the tool's local state files
+func stateDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".r53tool")
+}
+
+// changeBatchHash identifies a change batch submitted to a zone
+func changeBatchHash(zoneID string, batch route53.ChangeBatch) (string, error) {
+	data, err := xml.Marshal(batch)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(zoneID+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recentChanges maps change batch hashes to when they were submitted, so retried runs don't apply a change twice
+type recentChanges struct {
+	path      string
+	Submitted map[string]time.Time `json:"submitted"`
+}
+
+// loadRecentChanges reads the recent change state, a missing file is treated as empty
+func loadRecentChanges() (*recentChanges, error) {
+	r := &recentChanges{
+		path:      filepath.Join(stateDir(), recentChangesFile),
+		Submitted: make(map[string]time.Time),
+	}
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	if r.Submitted == nil {
+		r.Submitted = make(map[string]time.Time)
+	}
+	return r, nil
+}
+
+// seen returns when the hash was submitted if that was within window
+func (r *recentChanges) seen(hash string, window time.Duration) (time.Time, bool) {
+	at, exists := r.Submitted[hash]
+	if !exists || time.Since(at) > window {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// record stores the hash as submitted now, dropping entries older than window
+func (r *recentChanges) record(hash string, window time.Duration) error {
+	for h, at := range r.Submitted {
+		if time.Since(at) > window {
+			delete(r.Submitted, h)
+		}
+	}
+	r.Submitted[hash] = time.Now()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), stateDirPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, stateFilePermissions)
+}
+
+const (
+	appliedTokensFile = "applied_tokens.json"
+	// tokenRetention is how long an applied -idempotency-token is remembered
+	tokenRetention = 30 * 24 * time.Hour
+	// tokenCommentPrefix starts the change batch comment of runs with an -idempotency-token
+	tokenCommentPrefix = "r53tool idempotency-token="
+	// maxTokenLength keeps the comment within the 256 characters Route53 accepts
+	maxTokenLength = 256 - len(tokenCommentPrefix)
+)
+
+// appliedToken records when a run with an -idempotency-token completed and the changes it submitted
+type appliedToken struct {
+	At        time.Time `json:"at"`
+	ChangeIDs []string  `json:"change_ids,omitempty"`
+}
+
+// appliedTokens maps the -idempotency-token of completed runs to what they applied, so retried CI jobs become no-ops
+type appliedTokens struct {
+	path    string
+	Applied map[string]appliedToken `json:"applied"`
+}
+
+// loadAppliedTokens reads the applied token state, a missing file is treated as empty
+func loadAppliedTokens() (*appliedTokens, error) {
+	t := &appliedTokens{
+		path:    filepath.Join(stateDir(), appliedTokensFile),
+		Applied: make(map[string]appliedToken),
+	}
+	data, err := ioutil.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	if t.Applied == nil {
+		t.Applied = make(map[string]appliedToken)
+	}
+	return t, nil
+}
+
+// lookup returns the run that applied token, if it is still remembered
+func (t *appliedTokens) lookup(token string) (appliedToken, bool) {
+	applied, exists := t.Applied[token]
+	if !exists || time.Since(applied.At) > tokenRetention {
+		return appliedToken{}, false
+	}
+	return applied, true
+}
+
+// record stores token as applied now with the given change IDs, dropping entries older than tokenRetention
+func (t *appliedTokens) record(token string, changeIDs []string) error {
+	for k, applied := range t.Applied {
+		if time.Since(applied.At) > tokenRetention {
+			delete(t.Applied, k)
+		}
+	}
+	t.Applied[token] = appliedToken{At: time.Now(), ChangeIDs: changeIDs}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), stateDirPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.path, data, stateFilePermissions)
+}
+
+// tokenComment is the change batch comment carrying an -idempotency-token, so the change can be traced back to the run
+func tokenComment(token string) string {
+	return tokenCommentPrefix + token
+}
+
+// tokenApplied reports whether a run with c.idempotencyToken already completed, logging what it applied
+func (c *cli) tokenApplied() (bool, error) {
+	tokens, err := loadAppliedTokens()
+	if err != nil {
+		return false, fmt.Errorf("reading idempotency token state: %w", err)
+	}
+	applied, ok := tokens.lookup(c.idempotencyToken)
+	if !ok {
+		return false, nil
+	}
+	c.log.Printf("idempotency-token=%s already applied at %s changeIDs=%s, nothing to do\n", c.idempotencyToken, applied.At.Format(time.RFC3339), strings.Join(applied.ChangeIDs, ","))
+	return true, nil
+}
+
+// recordToken remembers c.idempotencyToken as applied, it is deferred by main so it only runs when the command succeeded
+func (c *cli) recordToken() {
+	if c.idempotencyToken == "" || c.dryRun {
+		return
+	}
+	tokens, err := loadAppliedTokens()
+	if err == nil {
+		err = tokens.record(c.idempotencyToken, c.changeIDs)
+	}
+	if err != nil {
+		c.log.Println("WARNING could not save idempotency token state", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// recordSetFilter selects record sets when listing a whole zone, empty fields match everything
+type recordSetFilter struct {
+	recordType string
+	namePrefix string
+	// nameContains matches names containing it anywhere
+	nameContains string
+	setID        string
+	// values matches record sets holding any of them, for alias record sets the target DNS name
+	values []string
+	// nameRegex and valueRegex match the name and any value, like values, of record sets
+	nameRegex  *regexp.Regexp
+	valueRegex *regexp.Regexp
+}
+
+// usesRegex reports whether the filter has a -name-regex or -value-regex, whose match counts are worth reporting
+func (f recordSetFilter) usesRegex() bool {
+	return f.nameRegex != nil || f.valueRegex != nil
+}
+
+// match reports whether rrs passes every filter that is set
+func (f recordSetFilter) match(rrs route53.ResourceRecordSet) bool {
+	if f.recordType != "" && stringValue(rrs.Type) != f.recordType {
+		return false
+	}
+	if f.namePrefix != "" && !strings.HasPrefix(unescapeName(stringValue(rrs.Name)), f.namePrefix) {
+		return false
+	}
+	if f.nameContains != "" && !strings.Contains(strings.ToLower(unescapeName(stringValue(rrs.Name))), strings.ToLower(f.nameContains)) {
+		return false
+	}
+	// record sets without a set identifier never match a -setid filter
+	if f.setID != "" && (rrs.SetIdentifier == nil || *rrs.SetIdentifier != f.setID) {
+		return false
+	}
+	if len(f.values) > 0 && !holdsAnyValue(rrs, f.values) {
+		return false
+	}
+	if f.nameRegex != nil && !f.nameRegex.MatchString(unescapeName(stringValue(rrs.Name))) {
+		return false
+	}
+	if f.valueRegex != nil && !holdsValueMatching(rrs, f.valueRegex) {
+		return false
+	}
+	return true
+}
+
+// holdsValueMatching reports whether one of the values of rrs matches re, alias record sets match by their target DNS name
+func holdsValueMatching(rrs route53.ResourceRecordSet, re *regexp.Regexp) bool {
+	if rrs.AliasTarget != nil && re.MatchString(stringValue(rrs.AliasTarget.DNSName)) {
+		return true
+	}
+	for _, rr := range rrs.ResourceRecords {
+		if re.MatchString(stringValue(rr.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsAnyValue reports whether rrs has one of the values, alias record sets are compared by their target DNS name
+func holdsAnyValue(rrs route53.ResourceRecordSet, values []string) bool {
+	var have []string
+	if rrs.AliasTarget != nil {
+		have = []string{normalizeName(stringValue(rrs.AliasTarget.DNSName))}
+	}
+	for _, rr := range rrs.ResourceRecords {
+		have = append(have, stringValue(rr.Value))
+	}
+	for _, h := range have {
+		for _, v := range values {
+			if strings.EqualFold(h, v) || (rrs.AliasTarget != nil && sameName(h, normalizeName(v))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listResourceRecordSets pages through every record set in the zone, keeping the ones matching filter
+func (c *cli) listResourceRecordSets(zoneID string, filter recordSetFilter) ([]route53.ResourceRecordSet, error) {
+	var sets []route53.ResourceRecordSet
+	req := &route53.ListResourceRecordSetsRequest{HostedZoneID: aws.String(zoneID)}
+	for {
+		if err := c.checkDeadline(); err != nil {
+			return nil, err
+		}
+		resp, err := c.r53.ListResourceRecordSets(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, rrs := range resp.ResourceRecordSets {
+			if filter.match(rrs) {
+				sets = append(sets, rrs)
+			}
+		}
+		if resp.IsTruncated == nil || !*resp.IsTruncated {
+			return sets, nil
+		}
+		req.StartRecordName = resp.NextRecordName
+		req.StartRecordType = resp.NextRecordType
+		req.StartRecordIdentifier = resp.NextRecordIdentifier
+	}
+}
+
+// listAllZones lists the record sets matching filter in every hosted zone of the account
+func (c *cli) listAllZones(filter recordSetFilter) ([]route53.ResourceRecordSet, error) {
+	zones, err := c.listHostedZones()
+	if err != nil {
+		return nil, fmt.Errorf("listing hosted zones %w", err)
+	}
+	var sets []route53.ResourceRecordSet
+	for _, zone := range zones {
+		zoneID, err := shortZoneID(stringValue(zone.ID))
+		if err != nil {
+			return nil, err
+		}
+		zoneSets, err := c.listResourceRecordSets(zoneID, filter)
+		if err != nil {
+			return nil, fmt.Errorf("listing resource record sets of %s %w", stringValue(zone.Name), err)
+		}
+		sets = append(sets, zoneSets...)
+	}
+	return sets, nil
+}
+
+// recordSetsByName returns every record set with the given name and type, whatever their set identifiers.
+// An empty recordType returns the record sets of every type.
+func (c *cli) recordSetsByName(zoneID, recordName, recordType string) ([]route53.ResourceRecordSet, error) {
+	recordName = normalizeName(recordName)
+	recordType = normalizeType(recordType)
+	var sets []route53.ResourceRecordSet
+	req := &route53.ListResourceRecordSetsRequest{
+		HostedZoneID:    aws.String(zoneID),
+		StartRecordName: aws.String(recordName),
+	}
+	if recordType != "" {
+		req.StartRecordType = aws.String(recordType)
+	}
+	for {
+		if err := c.checkDeadline(); err != nil {
+			return nil, err
+		}
+		resp, err := c.r53.ListResourceRecordSets(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, rrs := range resp.ResourceRecordSets {
+			// results are sorted by name and type, so the first other name or type ends the search
+			if !sameName(stringValue(rrs.Name), recordName) || (recordType != "" && stringValue(rrs.Type) != recordType) {
+				return sets, nil
+			}
+			sets = append(sets, rrs)
+		}
+		if resp.IsTruncated == nil || !*resp.IsTruncated {
+			return sets, nil
+		}
+		req.StartRecordName = resp.NextRecordName
+		req.StartRecordType = resp.NextRecordType
+		req.StartRecordIdentifier = resp.NextRecordIdentifier
+	}
+}
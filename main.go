@@ -3,77 +3,385 @@ package main
 // This tool is designed to be used by operations to add or remove IP addresses from AWS Route53 record sets
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/awslabs/aws-sdk-go/aws"
 	"github.com/awslabs/aws-sdk-go/gen/route53"
 )
 
 const defaultRegion = "us-east-1"
+
+// route53SigningRegion is the region requests to the global Route53 endpoint are signed for, whatever -region says
+const route53SigningRegion = "us-east-1"
+
+// commands lists the supported -cmd values
+const commands = "add|del|swap|prune|update|alias|list|list-all|list-zones|list-global|find-ip|from-dns|diff|convert|simplify|rebalance|cutover|watch|normalize-ttl|import-zone|undo|recent|validate"
 const version = "0.4"
 
+// defaultUserAgent identifies this tool in CloudTrail and API usage logs
+const defaultUserAgent = "r53tool/" + version
+
+// defaultMaxValues is the most values Route53 accepts in one record set
+const defaultMaxValues = 400
+
+// Route53 accepts at most 1000 changes in a single ChangeResourceRecordSets request
+const (
+	maxBatchSize     = 1000
+	defaultBatchSize = 500
+)
+
+// defaultTTL is used when creating a record set and -ttl isn't given
+const defaultTTL = 300
+
+// route53API is the part of the Route53 client the tool uses
+type route53API interface {
+	ChangeResourceRecordSets(*route53.ChangeResourceRecordSetsRequest) (*route53.ChangeResourceRecordSetsResponse, error)
+	GetHostedZone(*route53.GetHostedZoneRequest) (*route53.GetHostedZoneResponse, error)
+	ListHostedZones(*route53.ListHostedZonesRequest) (*route53.ListHostedZonesResponse, error)
+	ListResourceRecordSets(*route53.ListResourceRecordSetsRequest) (*route53.ListResourceRecordSetsResponse, error)
+	ListTagsForResource(*route53.ListTagsForResourceRequest) (*route53.ListTagsForResourceResponse, error)
+}
+
 type cli struct {
-	r53     *route53.Route53
-	log     *log.Logger
-	verbose bool
+	r53        route53API
+	region     string
+	log        *log.Logger
+	verbose    bool
+	multiValue bool
+	dryRun     bool
+	batchSize  int
+	failFast   bool
+	force      bool
+	maxValues  int
+	minHealthy int
+	preflight  preflight
+	webhook    string
+	zoneCache  *zoneCache
+	// hostedZones memoizes the hosted zones lookupZone searches, so a batch doesn't list them again for every entry
+	hostedZones []route53.HostedZone
+	// deleteIfEmpty turns changes removing the last value of a record set into a DELETE of it
+	deleteIfEmpty bool
+	// ttlMin and ttlMax bound the TTL of created and updated record sets, 0 means no bound
+	ttlMin, ttlMax int64
+	// ttl is set on updated record sets in the same change as their values, 0 keeps their TTL
+	ttl int64
+	// out receives listings, diffs, explanations and dry-run changes, stdout unless -output-file
+	out io.Writer
+	// changeFormat is how dry-run change batches are printed, xml unless -output=awscli
+	changeFormat string
+	// responseFormat is the -output format submitted ChangeInfo responses are printed in, empty unless -print-response
+	responseFormat string
+	// evaluateTargetHealth is set when -evaluate-target-health was given
+	evaluateTargetHealth *bool
+	// assumeYes answers confirmation prompts, see confirm
+	assumeYes bool
+	// autoConfirmKey and autoConfirmValue are the -auto-confirm-tag of zones changed without confirmation, see confirmZone
+	autoConfirmKey, autoConfirmValue string
+	// resolveCNAME makes validateRecordSet check CNAME targets resolve using resolver, empty for the system resolver
+	resolveCNAME bool
+	resolver     string
+
+	// ctx is canceled by the -timeout deadline and shutdown signals, see startTimeout and watchSignals
+	ctx context.Context
+	// dedupWindow enables skipping change batches identical to one submitted within the window
+	dedupWindow time.Duration
+	// idempotencyToken is put in the comment of submitted change batches, a run whose token was already applied does nothing
+	idempotencyToken string
+
+	// parallelZones is how many zones of a batch are submitted concurrently
+	parallelZones int
+	// mu guards the fields below and the idempotency state, zones may be submitted concurrently
+	mu sync.Mutex
+	// submittedChanges and changeIDs track what has been sent to Route53 during this run
+	submittedChanges int
+	changeIDs        []string
+	// failedChanges counts changes that couldn't be applied, for -pushgateway
+	failedChanges int
+	// priorStates holds the record set an UPSERT overwrites as its caller fetched it, see notePrior
+	priorStates map[*route53.ResourceRecordSet]*route53.ResourceRecordSet
+	// pendingBackup holds record sets to write to backupDir in backupFormat before the next submission, see backup
+	pendingBackup []route53.ResourceRecordSet
+	backupDir     string
+	backupFormat  string
+	// trackBaselines records the state applied changes leave record sets in and warns when it changed since, see checkDrift
+	trackBaselines bool
+
+	// pushgateway is the Prometheus Pushgateway the run's metrics are pushed to under pushJob, start is when the run began
+	pushgateway string
+	pushJob     string
+	start       time.Time
+
+	// undoing is set while undo submits reversals, which aren't logged for undo themselves
+	undoing bool
+	// auditLog is the file every applied record set change is appended to, see appendAudit
+	auditLog string
+	// strictSetID ignores a -setid given without a routing policy when creating record sets, see dropUnroutedSetID
+	strictSetID bool
+}
+
+// normalizeName makes a record name fully qualified by ensuring it ends with a dot.
+// Every function taking a record name normalizes it, so callers may pass names with or without the dot.
+func normalizeName(name string) string {
+	name = strings.TrimSpace(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// unescapeName decodes the \DDD octal escapes Route53 uses in returned names,
+// so a wildcard record stored as \052.example.com. matches *.example.com.
+func unescapeName(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if n, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
 }
 
-// recordToZone takes a dot-ending name which might include several labels and strips it down to the last two labels
+// sameName compares record names the way Route53 does, ignoring case and escaping
+func sameName(a, b string) bool {
+	return strings.EqualFold(unescapeName(a), unescapeName(b))
+}
+
+// normalizeType makes record type comparisons case-insensitive, Route53 uses upper case types
+func normalizeType(recordType string) string {
+	return strings.ToUpper(strings.TrimSpace(recordType))
+}
+
+// supportedType reports whether operations on the record type are supported
+func supportedType(recordType string) bool {
+	switch normalizeType(recordType) {
+	case "A", "AAAA", "CNAME", "CAA":
+		return true
+	}
+	return false
+}
+
+// recordToZone takes a name which might include several labels and strips it down to the last two labels.
+// An apex name like example.com. is its own zone.
 func recordToZone(name string) (string, error) {
-	labels := strings.Split(name, ".")
-	if len(labels) < 3 {
-		return "", fmt.Errorf("name must have at least one period")
+	// the trailing dot leaves an empty last label
+	labels := strings.Split(normalizeName(name), ".")
+	if len(labels) < 3 || labels[len(labels)-2] == "" || labels[len(labels)-3] == "" {
+		return "", fmt.Errorf("name %q must have at least two labels, like example.com", name)
 	}
 	return strings.Join(labels[len(labels)-3:], "."), nil
 }
 
-// zoneIDByName takes a dot-ending record name and returns the Route53 zone ID
-// TODO: handle paging
+// zoneIDByName takes a record name and returns the Route53 zone ID
 func (c *cli) zoneIDByName(recordName string) (string, error) {
+	zone, err := c.lookupZone(recordName)
+	return zone.id, err
+}
 
+// zoneRef is the hosted zone selected for a record name
+type zoneRef struct {
+	name string
+	id   string
+	// cached is set when the ID came from the -zone-cache file rather than ListHostedZones
+	cached bool
+	// private is set for private hosted zones, it's unknown for cached zones
+	private bool
+}
+
+// lookupZone finds the hosted zone for a record name. A zone named like the record itself
+// (an apex record such as example.co.uk.) wins over the zone guessed from the last two labels.
+func (c *cli) lookupZone(recordName string) (zoneRef, error) {
+	recordName = normalizeName(recordName)
 	name, err := recordToZone(recordName)
 	if err != nil {
-		return "", err
+		return zoneRef{}, err
 	}
-	req := &route53.ListHostedZonesRequest{}
-	for {
-		resp, err := c.r53.ListHostedZones(req)
-		if err != nil {
-			return "", err
-		}
-		for _, zone := range resp.HostedZones {
-			if *zone.Name == name {
-				// zone.ID looks like /hostedzone/Z22CR2RGPPKRQB but we just want the last part
-				components := strings.Split(*zone.ID, "/")
-				if len(components) != 3 {
-					return "", fmt.Errorf("problem splitting id from %s\n", *zone.ID)
-				}
-				zoneID := components[len(components)-1]
-				if c.verbose {
-					c.log.Printf("zoneName=%s zoneID=%s\n", name, zoneID)
-				}
-				return zoneID, nil
+	candidates := []string{recordName}
+	if name != recordName {
+		candidates = append(candidates, name)
+	}
+	if c.zoneCache != nil {
+		for _, candidate := range candidates {
+			if zoneID, exists := c.zoneCache.get(candidate); exists {
+				// always reported, so a record landing in the wrong zone of a split horizon is noticed
+				c.log.Printf("using zoneName=%s zoneID=%s (cached)\n", candidate, zoneID)
+				return zoneRef{name: candidate, id: zoneID, cached: true}, nil
 			}
 		}
-		if !*resp.IsTruncated {
-			return "", fmt.Errorf("zone %s not found", name)
+	}
+
+	if c.hostedZones == nil {
+		if c.hostedZones, err = c.listHostedZones(); err != nil {
+			return zoneRef{}, err
+		}
+	}
+	zones := c.hostedZones
+	var seen []string
+	for _, zone := range zones {
+		seen = append(seen, *zone.Name)
+	}
+	for _, candidate := range candidates {
+		for _, zone := range zones {
+			if *zone.Name == candidate {
+				return c.selectZone(zone)
+			}
+		}
+	}
+
+	// fall back to the hosted zone the record falls under, as long as only one does
+	var under []route53.HostedZone
+	for _, zone := range zones {
+		if strings.HasSuffix(strings.ToLower(recordName), "."+strings.ToLower(*zone.Name)) {
+			under = append(under, zone)
 		}
-		req.Marker = resp.NextMarker
 	}
+	if len(under) == 1 {
+		return c.selectZone(under[0])
+	}
+	if len(under) > 1 {
+		var names []string
+		for _, zone := range under {
+			names = append(names, *zone.Name)
+		}
+		return zoneRef{}, fmt.Errorf("zone %s not found and %s is under several zones (%s), pass -zoneid", name, recordName, strings.Join(names, ", "))
+	}
+	if similar := similarZones(recordName, name, seen); len(similar) > 0 {
+		return zoneRef{}, fmt.Errorf("zone %s not found, did you mean one of %s", name, strings.Join(similar, ", "))
+	}
+	return zoneRef{}, fmt.Errorf("zone %s not found", name)
+}
+
+// selectZone returns the reference to a hosted zone found by lookupZone, remembering it in the zone cache
+func (c *cli) selectZone(zone route53.HostedZone) (zoneRef, error) {
+	zoneID, err := shortZoneID(*zone.ID)
+	if err != nil {
+		return zoneRef{}, err
+	}
+	ref := zoneRef{name: *zone.Name, id: zoneID}
+	if zone.Config != nil && zone.Config.PrivateZone != nil {
+		ref.private = *zone.Config.PrivateZone
+	}
+	// always reported, so a record landing in the wrong zone of a split horizon is noticed
+	c.log.Printf("using zoneName=%s zoneID=%s private=%t\n", ref.name, ref.id, ref.private)
+	if c.zoneCache != nil {
+		if err := c.zoneCache.set(*zone.Name, zoneID); err != nil {
+			c.log.Println("WARNING could not update zone cache", err)
+		}
+	}
+	return ref, nil
+}
+
+// similarZones picks the zones that look like a typo of, or a better match for, the zone we looked for:
+// zones the record name falls under, zones under the wanted zone, and zones sharing its first label
+func similarZones(recordName, zoneName string, zones []string) []string {
+	firstLabel := strings.SplitN(zoneName, ".", 2)[0] + "."
+	var similar []string
+	for _, zone := range zones {
+		switch {
+		case strings.HasSuffix(recordName, "."+zone),
+			strings.HasSuffix(zone, "."+zoneName),
+			strings.HasPrefix(zone, firstLabel):
+			similar = append(similar, zone)
+		}
+	}
+	return similar
+}
+
+// apiErrorCode returns the AWS error code carried by err or an error it wraps, or "" if err did not come from the API
+func apiErrorCode(err error) string {
+	var apiErr aws.APIError
+	var apiErrPtr *aws.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Code
+	case errors.As(err, &apiErrPtr):
+		return apiErrPtr.Code
+	}
+	return ""
+}
+
+// isAccessDenied reports whether err is an AWS permission error
+func isAccessDenied(err error) bool {
+	switch apiErrorCode(err) {
+	case "AccessDenied", "AccessDeniedException", "UnauthorizedOperation":
+		return true
+	}
+	return false
+}
+
+// zoneLookupError turns a zoneIDByName error into the error shown to the user
+func zoneLookupError(err error) error {
+	if isAccessDenied(err) {
+		return codedError{codeAccessDenied, fmt.Errorf("getting zoneid: access denied, the route53:ListHostedZones permission is required to look up the zone by name (pass -zoneid to skip the lookup)")}
+	}
+	return fmt.Errorf("getting zoneid %w", err)
+}
+
+// evaluatesTargetHealth reports whether an alias record set has EvaluateTargetHealth enabled
+func evaluatesTargetHealth(rrs route53.ResourceRecordSet) bool {
+	return rrs.AliasTarget != nil && rrs.AliasTarget.EvaluateTargetHealth != nil && *rrs.AliasTarget.EvaluateTargetHealth
+}
+
+// isMultiValue reports whether the record set uses multivalue answer routing
+func isMultiValue(rrs route53.ResourceRecordSet) bool {
+	return rrs.MultiValueAnswer != nil && *rrs.MultiValueAnswer
+}
+
+// setMultiValue marks the record set for multivalue answer routing.
+// Multivalue records need a set identifier and cannot carry another routing policy.
+func setMultiValue(rrs *route53.ResourceRecordSet) error {
+	if rrs.SetIdentifier == nil || *rrs.SetIdentifier == "" {
+		return fmt.Errorf("multivalue answer records require a set identifier")
+	}
+	switch {
+	case rrs.Weight != nil:
+		return fmt.Errorf("multivalue answer cannot be combined with weighted routing")
+	case rrs.Region != nil:
+		return fmt.Errorf("multivalue answer cannot be combined with latency routing")
+	case rrs.Failover != nil:
+		return fmt.Errorf("multivalue answer cannot be combined with failover routing")
+	case rrs.GeoLocation != nil:
+		return fmt.Errorf("multivalue answer cannot be combined with geolocation routing")
+	case rrs.AliasTarget != nil:
+		return fmt.Errorf("multivalue answer cannot be used on alias records")
+	}
+	rrs.MultiValueAnswer = aws.Boolean(true)
+	return nil
 }
 
 // printResourceRecordSet is a pretty printer
-func printResourceRecordSet(rrs route53.ResourceRecordSet) {
-	enc := xml.NewEncoder(os.Stdout)
+func printResourceRecordSet(w io.Writer, rrs route53.ResourceRecordSet) error {
+	enc := xml.NewEncoder(w)
 	enc.Indent("", "  ")
-	enc.Encode(rrs)
+	if err := enc.Encode(rrs); err != nil {
+		return err
+	}
+	if isMultiValue(rrs) {
+		if _, err := fmt.Fprintln(w, "\nrouting policy: multivalue answer"); err != nil {
+			return err
+		}
+	}
 	log.Println()
+	return nil
 }
 
 func mapKeys(data map[string]struct{}) []string {
@@ -85,12 +393,392 @@ func mapKeys(data map[string]struct{}) []string {
 
 }
 
-// delFromARecordResourceRecordSet deletes one or more IP addresses from the Resource Record Set
-func (c *cli) delFromARecordResourceRecordSet(zoneID string, rrs route53.ResourceRecordSet, ips ...string) error {
-	if len(ips) == 0 {
-		return fmt.Errorf("at least one IP needs to be passed")
+// splitValues expands comma-joined arguments into separate values, dropping empties and duplicates.
+// Commas inside quoted CAA values don't separate values.
+func splitValues(args []string) []string {
+	seen := make(map[string]struct{})
+	var values []string
+	for _, arg := range args {
+		for _, v := range splitUnquoted(arg, ',', -1) {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			if _, exists := seen[v]; exists {
+				continue
+			}
+			seen[v] = struct{}{}
+			values = append(values, v)
+		}
 	}
+	return values
+}
+
+// copyResourceRecordSet returns a copy of rrs that can have its records modified without touching the original
+func copyResourceRecordSet(rrs route53.ResourceRecordSet) route53.ResourceRecordSet {
+	dup := rrs
+	dup.ResourceRecords = append([]route53.ResourceRecord(nil), rrs.ResourceRecords...)
+	return dup
+}
+
+// sameResourceRecordSet reports whether two record sets would produce the same DNS answers,
+// treating the records as an unordered set
+func sameResourceRecordSet(a, b route53.ResourceRecordSet) bool {
+	return canonicalRecordSet(a) == canonicalRecordSet(b)
+}
+
+// applyOverrides applies record set settings given on the command line to rrs before it is submitted
+func (c *cli) applyOverrides(rrs *route53.ResourceRecordSet) error {
+	if c.ttl > 0 && rrs.AliasTarget == nil {
+		rrs.TTL = aws.Long(c.ttl)
+	}
+	if c.multiValue {
+		if err := setMultiValue(rrs); err != nil {
+			return err
+		}
+	}
+	if c.evaluateTargetHealth != nil {
+		if rrs.AliasTarget == nil {
+			return fmt.Errorf("-evaluate-target-health only applies to alias record sets")
+		}
+		// copy the alias target so the record set it came from keeps its value
+		target := *rrs.AliasTarget
+		target.EvaluateTargetHealth = aws.Boolean(*c.evaluateTargetHealth)
+		rrs.AliasTarget = &target
+	}
+	return nil
+}
 
+// validateRecordSet checks rrs against limits Route53 would otherwise reject it for, and the -ttl-min/-ttl-max policy
+func (c *cli) validateRecordSet(rrs route53.ResourceRecordSet) error {
+	if c.maxValues > 0 && len(rrs.ResourceRecords) > c.maxValues {
+		return fmt.Errorf("%s would have %d values, more than the maximum of %d", stringValue(rrs.Name), len(rrs.ResourceRecords), c.maxValues)
+	}
+	policy := routingPolicy(rrs)
+	switch {
+	case policy != "simple" && stringValue(rrs.SetIdentifier) == "":
+		return fmt.Errorf("%s uses %s routing which needs a set identifier, pass -setid", stringValue(rrs.Name), policy)
+	case policy == "simple" && rrs.SetIdentifier != nil:
+		c.log.Printf("WARNING %s has set identifier %s but no routing policy using it\n", stringValue(rrs.Name), *rrs.SetIdentifier)
+	}
+	if err := validateSetID(stringValue(rrs.SetIdentifier)); err != nil {
+		return err
+	}
+	if rrs.TTL != nil {
+		if c.ttlMin > 0 && *rrs.TTL < c.ttlMin {
+			return fmt.Errorf("%s would have TTL %d, below the -ttl-min of %d", stringValue(rrs.Name), *rrs.TTL, c.ttlMin)
+		}
+		if c.ttlMax > 0 && *rrs.TTL > c.ttlMax {
+			return fmt.Errorf("%s would have TTL %d, above the -ttl-max of %d", stringValue(rrs.Name), *rrs.TTL, c.ttlMax)
+		}
+	}
+	return c.checkCNAMETarget(rrs)
+}
+
+// createChange builds the CREATE for rrs. An existing record set with the same name, type and set identifier
+// is refused, so another datacenter's weighted record isn't clobbered, unless -force turns it into an UPSERT.
+func (c *cli) createChange(zoneID string, rrs route53.ResourceRecordSet) (*route53.Change, error) {
+	current, err := c.getResourceRecordSet(zoneID, stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+	switch {
+	case isNotFound(err):
+		if err := c.checkCNAMEConflict(zoneID, rrs); err != nil {
+			return nil, err
+		}
+		return &route53.Change{Action: aws.String("CREATE"), ResourceRecordSet: &rrs}, nil
+	case err != nil:
+		return nil, err
+	case !c.force:
+		return nil, fmt.Errorf("%s %s setIdentifier=%s already exists, use -force to overwrite it", stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+	}
+	c.log.Printf("WARNING overwriting existing %s %s setIdentifier=%s\n", stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+	c.notePrior(&rrs, &current)
+	return &route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &rrs}, nil
+}
+
+// createWithValues submits a new record set holding values, action is CREATE, failing when the record set
+// already exists, or UPSERT
+func (c *cli) createWithValues(zoneID string, rrs route53.ResourceRecordSet, action string, values ...string) error {
+	created := c.withValuesAdded(rrs, values...)
+	if err := c.applyOverrides(&created); err != nil {
+		return err
+	}
+	c.dropUnroutedSetID(&created)
+	if err := c.validateRecordSet(created); err != nil {
+		return err
+	}
+	if err := c.checkCNAMEConflict(zoneID, created); err != nil {
+		return err
+	}
+	if err := c.submitChanges(zoneID, []route53.Change{{Action: aws.String(action), ResourceRecordSet: &created}}); err != nil {
+		return err
+	}
+	if !c.dryRun {
+		c.log.Printf("created %s with %d records\n", stringValue(created.Name), len(created.ResourceRecords))
+	}
+	return nil
+}
+
+// createForAction handles the -action of an add given the error looking up the record set, nil when it exists.
+// create and upsert submit rrs with the values when it doesn't exist and create refuses one that does.
+// done is false when the values are to be added to the existing record set, or its absence reported, as without -action.
+func (c *cli) createForAction(zoneID, changeAction string, rrs route53.ResourceRecordSet, lookupErr error, values []string) (done bool, err error) {
+	if changeAction != "create" && changeAction != "upsert" {
+		return false, nil
+	}
+	switch {
+	case isNotFound(lookupErr):
+		values, err := c.preflightIPs(values)
+		if err != nil {
+			return true, err
+		}
+		return true, c.createWithValues(zoneID, rrs, strings.ToUpper(changeAction), values...)
+	case lookupErr == nil && changeAction == "create":
+		return true, fmt.Errorf("%s %s setIdentifier=%s already exists, -action=create only adds to new record sets", stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+	}
+	return false, nil
+}
+
+// dropUnroutedSetID enforces -strict-setid on a record set created from flags. Route53 only accepts a set identifier
+// along with a routing policy, so without one the set identifier is ignored and a simple record set created.
+func (c *cli) dropUnroutedSetID(rrs *route53.ResourceRecordSet) {
+	if !c.strictSetID || rrs.SetIdentifier == nil || routingPolicy(*rrs) != "simple" {
+		return
+	}
+	c.log.Printf("WARNING -setid=%s is given without a routing policy such as -weight, %s will be a simple record set ignoring it\n", *rrs.SetIdentifier, stringValue(rrs.Name))
+	rrs.SetIdentifier = nil
+}
+
+// upsertChange builds the UPSERT turning current into updated, returning nil when nothing would change
+func (c *cli) upsertChange(current, updated route53.ResourceRecordSet) (*route53.Change, error) {
+	if err := c.applyOverrides(&updated); err != nil {
+		return nil, err
+	}
+	if sameResourceRecordSet(current, updated) {
+		return nil, nil
+	}
+	if remaining := len(updated.ResourceRecords); c.minHealthy > 0 && remaining < len(current.ResourceRecords) && remaining < c.minHealthy {
+		return nil, fmt.Errorf("%s would be left with %d values, fewer than -min-healthy=%d", stringValue(updated.Name), remaining, c.minHealthy)
+	}
+	if len(updated.ResourceRecords) == 0 && updated.AliasTarget == nil {
+		if !c.deleteIfEmpty {
+			return nil, fmt.Errorf("%s would be left without values, use -delete-if-empty to delete the record set instead", stringValue(updated.Name))
+		}
+		c.log.Printf("removing the last value of %s %s setIdentifier=%s, deleting the record set (-delete-if-empty)\n", stringValue(current.Name), stringValue(current.Type), stringValue(current.SetIdentifier))
+		return &route53.Change{Action: aws.String("DELETE"), ResourceRecordSet: &current}, nil
+	}
+	if err := c.validateRecordSet(updated); err != nil {
+		return nil, err
+	}
+	if current.Name != nil {
+		c.notePrior(&updated, &current)
+	}
+	return &route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &updated}, nil
+}
+
+// upsertResourceRecordSet submits updated as an UPSERT, skipping the call when it doesn't differ from current
+func (c *cli) upsertResourceRecordSet(zoneID string, current, updated route53.ResourceRecordSet) error {
+	change, err := c.upsertChange(current, updated)
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		c.log.Println("no change needed")
+		return nil
+	}
+	return c.submitChanges(zoneID, []route53.Change{*change})
+}
+
+// splitChanges breaks changes into consecutive batches of at most size changes each
+func splitChanges(changes []route53.Change, size int) [][]route53.Change {
+	var batches [][]route53.Change
+	for len(changes) > size {
+		batches = append(batches, changes[:size])
+		changes = changes[size:]
+	}
+	if len(changes) > 0 {
+		batches = append(batches, changes)
+	}
+	return batches
+}
+
+// submitChanges sends the changes to Route53, split into batches of at most c.batchSize changes
+// which are submitted one after another. When dry-run is set the batches are only printed.
+// A failed batch stops the submission when failFast is set, otherwise the remaining batches are still sent.
+func (c *cli) submitChanges(zoneID string, changes []route53.Change) error {
+	_, err := c.submitBatches(zoneID, changes)
+	return err
+}
+
+// submitAtomic sends the changes in a single change batch whatever the -batch-size, so Route53 applies all or none of them
+func (c *cli) submitAtomic(zoneID string, changes []route53.Change) error {
+	if len(changes) > maxBatchSize {
+		return fmt.Errorf("%d changes are more than the %d of one atomic batch", len(changes), maxBatchSize)
+	}
+	batchSize := c.batchSize
+	c.batchSize = maxBatchSize
+	defer func() { c.batchSize = batchSize }()
+	return c.submitChanges(zoneID, changes)
+}
+
+// submitBatches is submitChanges also returning how many of the changes were submitted.
+// It is safe to call for different zones concurrently.
+func (c *cli) submitBatches(zoneID string, changes []route53.Change) (int, error) {
+	if !c.dryRun {
+		if err := c.writeBackup(); err != nil {
+			return 0, fmt.Errorf("writing backup, nothing submitted: %w", err)
+		}
+	}
+	if c.trackBaselines {
+		c.checkDrift(zoneID, changes)
+	}
+	batches := splitChanges(changes, c.batchSize)
+	var failed []string
+	submitted := 0
+	for i, batch := range batches {
+		if c.interrupted() {
+			return submitted, errInterrupted
+		}
+		changeBatch := route53.ChangeBatch{Changes: batch}
+		if c.idempotencyToken != "" {
+			changeBatch.Comment = aws.String(tokenComment(c.idempotencyToken))
+		}
+		if c.dryRun {
+			c.log.Printf("dry-run: not submitting batch %d/%d with %d change(s) to zoneID=%s\n", i+1, len(batches), len(batch), zoneID)
+			if c.changeFormat == outputAWSCLI {
+				if err := printAWSCLIBatch(c.out, changeBatch); err != nil {
+					return submitted, err
+				}
+				continue
+			}
+			enc := xml.NewEncoder(c.out)
+			enc.Indent("", "  ")
+			if err := enc.Encode(changeBatch); err != nil {
+				return submitted, err
+			}
+			fmt.Fprintln(c.out)
+			continue
+		}
+
+		var recent *recentChanges
+		var hash string
+		if c.dedupWindow > 0 {
+			var err error
+			if recent, err = loadRecentChanges(); err != nil {
+				return submitted, fmt.Errorf("reading idempotency state: %w", err)
+			}
+			if hash, err = changeBatchHash(zoneID, changeBatch); err != nil {
+				return submitted, err
+			}
+			if at, seen := recent.seen(hash, c.dedupWindow); seen {
+				c.log.Printf("identical change batch already submitted at %s, skipping\n", at.Format(time.RFC3339))
+				continue
+			}
+		}
+
+		var inverse []route53.Change
+		if !c.undoing || c.auditLog != "" {
+			var err error
+			if inverse, err = c.inverseChanges(zoneID, batch); err != nil {
+				c.log.Println("WARNING could not look up the current state to log for undo", err)
+			}
+		}
+
+		req := &route53.ChangeResourceRecordSetsRequest{HostedZoneID: aws.String(zoneID)}
+		req.ChangeBatch = &changeBatch
+		resp, err := c.changeResourceRecordSets(req)
+		if err != nil {
+			if len(batches) == 1 {
+				c.countFailed(len(batch))
+				return submitted, err
+			}
+			c.countFailed(len(batch))
+			err = fmt.Errorf("batch %d/%d: %s", i+1, len(batches), err)
+			if c.failFast {
+				return submitted, err
+			}
+			c.log.Println("ERROR", err)
+			failed = append(failed, fmt.Sprint(i+1))
+			continue
+		}
+		submitted += len(batch)
+		c.mu.Lock()
+		c.submittedChanges += len(batch)
+		c.changeIDs = append(c.changeIDs, stringValue(resp.ChangeInfo.ID))
+		if recent != nil {
+			// reload so batches recorded for other zones in the meantime are kept
+			if latest, err := loadRecentChanges(); err == nil {
+				recent = latest
+			}
+			if err := recent.record(hash, c.dedupWindow); err != nil {
+				c.log.Println("WARNING could not save idempotency state", err)
+			}
+		}
+		c.mu.Unlock()
+		if c.trackBaselines {
+			if err := c.recordBaselines(zoneID, batch); err != nil {
+				c.log.Println("WARNING could not save baselines", err)
+			}
+		}
+		if inverse != nil && !c.undoing {
+			if err := c.recordUndo(zoneID, batch, inverse); err != nil {
+				c.log.Println("WARNING could not save the undo log", err)
+			}
+		}
+		if c.auditLog != "" {
+			if err := c.appendAudit(auditRecords(zoneID, stringValue(resp.ChangeInfo.ID), batch, inverse)); err != nil {
+				c.log.Println("WARNING could not write the audit log", err)
+			}
+		}
+		c.notifyWebhook(zoneID, batch, resp.ChangeInfo)
+		if len(batches) > 1 {
+			c.log.Printf("batch %d/%d submitted with %d change(s) changeID=%s\n", i+1, len(batches), len(batch), stringValue(resp.ChangeInfo.ID))
+		}
+		if c.responseFormat != "" && resp.ChangeInfo != nil {
+			if err := printChangeInfo(c.out, c.responseFormat, *resp.ChangeInfo); err != nil {
+				return submitted, fmt.Errorf("writing response %w", err)
+			}
+		}
+		if c.verbose {
+			c.log.Printf("ChangeResourceRecordSets responseStatus=%+v responseComment=%s responseID=%+v\n", stringValue(resp.ChangeInfo.Status), stringValue(resp.ChangeInfo.Comment), stringValue(resp.ChangeInfo.ID))
+		}
+	}
+	if len(failed) > 0 {
+		return submitted, fmt.Errorf("%d of %d batches failed (%s)", len(failed), len(batches), strings.Join(failed, ","))
+	}
+	return submitted, nil
+}
+
+// priorRequestRetries is how often a change batch is retried while Route53 is still applying an earlier change to the zone
+const (
+	priorRequestRetries = 5
+	priorRequestBackoff = time.Second
+)
+
+// sleep waits between retries, tests replace it to not wait
+var sleep = time.Sleep
+
+// changeResourceRecordSets submits a change batch, retrying with a doubling backoff while Route53 answers
+// PriorRequestNotComplete, which it does when changes for one zone are submitted in quick succession
+func (c *cli) changeResourceRecordSets(req *route53.ChangeResourceRecordSetsRequest) (*route53.ChangeResourceRecordSetsResponse, error) {
+	backoff := priorRequestBackoff
+	for attempt := 0; ; attempt++ {
+		if err := c.checkDeadline(); err != nil {
+			return nil, err
+		}
+		resp, err := c.r53.ChangeResourceRecordSets(req)
+		if err == nil || apiErrorCode(err) != "PriorRequestNotComplete" || attempt == priorRequestRetries {
+			return resp, err
+		}
+		if c.verbose {
+			c.log.Printf("prior request for zoneID=%s not complete, retrying in %s\n", stringValue(req.HostedZoneID), backoff)
+		}
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// withValuesRemoved returns a copy of rrs without the given values
+func (c *cli) withValuesRemoved(rrs route53.ResourceRecordSet, ips ...string) route53.ResourceRecordSet {
 	// put the slice into a map so we can easily determine if an existing record is in our list to delete
 	ipMap := make(map[string]struct{})
 	for _, ip := range ips {
@@ -115,19 +803,35 @@ func (c *cli) delFromARecordResourceRecordSet(zoneID string, rrs route53.Resourc
 	if c.verbose && len(ipMap) > 0 {
 		c.log.Printf("IPs not found to delete %v\n", mapKeys(ipMap))
 	}
+	return rrs
+}
 
-	req := &route53.ChangeResourceRecordSetsRequest{HostedZoneID: aws.String(zoneID)}
-	change := route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &rrs}
-	changeBatch := route53.ChangeBatch{Changes: []route53.Change{change}}
-	req.ChangeBatch = &changeBatch
-	resp, err := c.r53.ChangeResourceRecordSets(req)
-	if err != nil {
-		return err
+// withValuesAdded returns a copy of rrs with the given values added, skipping ones already present
+func (c *cli) withValuesAdded(rrs route53.ResourceRecordSet, ips ...string) route53.ResourceRecordSet {
+	updated := copyResourceRecordSet(rrs)
+	existing := make(map[string]struct{})
+	for _, rr := range rrs.ResourceRecords {
+		existing[*rr.Value] = struct{}{}
 	}
-	if c.verbose {
-		c.log.Printf("ChangeResourceRecordSets response=%+v\n", *resp.ChangeInfo.Status)
+	for _, ip := range ips {
+		if _, exists := existing[ip]; exists {
+			if c.verbose {
+				c.log.Printf("IP %s already present\n", ip)
+			}
+			continue
+		}
+		existing[ip] = struct{}{}
+		updated.ResourceRecords = append(updated.ResourceRecords, route53.ResourceRecord{Value: aws.String(ip)})
 	}
-	return nil
+	return updated
+}
+
+// delFromARecordResourceRecordSet deletes one or more IP addresses from the Resource Record Set
+func (c *cli) delFromARecordResourceRecordSet(zoneID string, rrs route53.ResourceRecordSet, ips ...string) error {
+	if len(ips) == 0 {
+		return fmt.Errorf("at least one IP needs to be passed")
+	}
+	return c.upsertResourceRecordSet(zoneID, rrs, c.withValuesRemoved(rrs, ips...))
 }
 
 // addToARecordResourceRecordSet adds one or more IP addresses to the Resource Record Set
@@ -135,62 +839,282 @@ func (c *cli) addToARecordResourceRecordSet(zoneID string, rrs route53.ResourceR
 	if len(ips) == 0 {
 		return fmt.Errorf("at least one IP needs to be passed")
 	}
-	req := &route53.ChangeResourceRecordSetsRequest{HostedZoneID: aws.String(zoneID)}
-	for _, ip := range ips {
-		rrs.ResourceRecords = append(rrs.ResourceRecords, route53.ResourceRecord{Value: aws.String(ip)})
+	return c.upsertResourceRecordSet(zoneID, rrs, c.withValuesAdded(rrs, ips...))
+}
+
+// delFromAllSetIDs removes IP addresses from every record set in sets with a single batched UPSERT
+func (c *cli) delFromAllSetIDs(zoneID string, sets []route53.ResourceRecordSet, ips ...string) error {
+	if len(ips) == 0 {
+		return fmt.Errorf("at least one IP needs to be passed")
+	}
+	var changes []route53.Change
+	var modified []string
+	for _, rrs := range sets {
+		change, err := c.upsertChange(rrs, c.withValuesRemoved(rrs, ips...))
+		if err != nil {
+			return fmt.Errorf("setIdentifier=%s: %s", stringValue(rrs.SetIdentifier), err)
+		}
+		if change == nil {
+			continue
+		}
+		changes = append(changes, *change)
+		modified = append(modified, stringValue(rrs.SetIdentifier))
+		c.backup(rrs)
+	}
+	if len(changes) == 0 {
+		c.log.Println("no change needed")
+		return nil
+	}
+	if err := c.submitChanges(zoneID, changes); err != nil {
+		return err
 	}
-	change := route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &rrs}
-	changeBatch := route53.ChangeBatch{Changes: []route53.Change{change}}
-	req.ChangeBatch = &changeBatch
-	resp, err := c.r53.ChangeResourceRecordSets(req)
+	c.log.Printf("removed %v from set identifiers %v\n", ips, modified)
+	return nil
+}
+
+// swapValues replaces old values with new ones in a single UPSERT, so the record set never lacks an entry in between.
+// Every old value must be present and no new value may already be.
+func (c *cli) swapValues(zoneID string, rrs route53.ResourceRecordSet, pairs []swapPair) error {
+	change, err := c.swapChange(rrs, pairs)
 	if err != nil {
 		return err
 	}
-	if c.verbose {
-		c.log.Printf("ChangeResourceRecordSets responseStatus=%+v responseComment=%s responseID=%+v\n", *resp.ChangeInfo.Status, *resp.ChangeInfo.Comment, *resp.ChangeInfo.ID)
+	if change == nil {
+		c.log.Println("no change needed")
+		return nil
+	}
+	return c.submitChanges(zoneID, []route53.Change{*change})
+}
+
+// swapChange builds the UPSERT replacing the old values of each pair in rrs with the new ones
+func (c *cli) swapChange(rrs route53.ResourceRecordSet, pairs []swapPair) (*route53.Change, error) {
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("at least one old=new pair needs to be passed")
+	}
+	recordType := stringValue(rrs.Type)
+	existing := make(map[string]struct{})
+	for _, rr := range rrs.ResourceRecords {
+		existing[canonicalValue(recordType, stringValue(rr.Value))] = struct{}{}
+	}
+	var olds, news []string
+	seen := make(map[string]struct{})
+	for _, p := range pairs {
+		p = swapPair{old: canonicalValue(recordType, p.old), new: canonicalValue(recordType, p.new)}
+		if _, exists := existing[p.old]; !exists {
+			return nil, fmt.Errorf("%s is not in the record set", p.old)
+		}
+		if err := validateValue(recordType, p.new); err != nil {
+			return nil, err
+		}
+		if _, exists := existing[p.new]; exists {
+			return nil, fmt.Errorf("%s is already in the record set", p.new)
+		}
+		if _, dup := seen[p.new]; dup {
+			return nil, fmt.Errorf("%s is given more than once", p.new)
+		}
+		seen[p.new] = struct{}{}
+		olds = append(olds, p.old)
+		news = append(news, p.new)
+	}
+	updated := c.withValuesAdded(c.withValuesRemoved(rrs, olds...), news...)
+	return c.upsertChange(rrs, updated)
+}
+
+// pruneValues removes every value of rrs not in keep, so the record set matches an allowlist.
+// Values of keep missing from rrs aren't added, and a prune leaving nothing is refused.
+func (c *cli) pruneValues(zoneID string, rrs route53.ResourceRecordSet, keep ...string) error {
+	if len(keep) == 0 {
+		return fmt.Errorf("at least one IP to keep needs to be passed")
+	}
+	allowed := make(map[string]struct{})
+	for _, v := range keep {
+		allowed[v] = struct{}{}
+	}
+	var pruned []string
+	for _, rr := range rrs.ResourceRecords {
+		if _, ok := allowed[stringValue(rr.Value)]; !ok {
+			pruned = append(pruned, stringValue(rr.Value))
+		}
+	}
+	if len(pruned) == len(rrs.ResourceRecords) && !c.deleteIfEmpty {
+		return fmt.Errorf("prune would leave %s without values, use -delete-if-empty to delete the record set instead", stringValue(rrs.Name))
+	}
+	if len(pruned) == 0 && c.ttl == 0 {
+		c.log.Println("no change needed")
+		return nil
+	}
+	if err := c.upsertResourceRecordSet(zoneID, rrs, c.withValuesRemoved(rrs, pruned...)); err != nil {
+		return err
+	}
+	if !c.dryRun {
+		c.log.Printf("pruned %v from %s\n", pruned, stringValue(rrs.Name))
 	}
 	return nil
 }
 
+// newResourceRecordSet builds a record set that doesn't exist in Route53 yet
+func newResourceRecordSet(recordName, recordType, setID string, ttl int64) route53.ResourceRecordSet {
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	rrs := route53.ResourceRecordSet{
+		Name: aws.String(normalizeName(recordName)),
+		Type: aws.String(normalizeType(recordType)),
+		TTL:  aws.Long(ttl),
+	}
+	if setID != "" {
+		rrs.SetIdentifier = aws.String(setID)
+	}
+	return rrs
+}
+
 // getResourceRecordSet finds an existing resource record set matching the criteria
 func (c *cli) getResourceRecordSet(zoneID string, recordName string, recordType string, setID string) (route53.ResourceRecordSet, error) {
+	recordName = normalizeName(recordName)
+	recordType = normalizeType(recordType)
 	req := route53.ListResourceRecordSetsRequest{HostedZoneID: &zoneID}
 	req.StartRecordName = aws.String(recordName)
 	req.StartRecordType = aws.String(recordType)
+	if err := c.checkDeadline(); err != nil {
+		return route53.ResourceRecordSet{}, err
+	}
 	resp, err := c.r53.ListResourceRecordSets(&req)
 	if err != nil {
 		return route53.ResourceRecordSet{}, err
 	}
 
 	for _, rrs := range resp.ResourceRecordSets {
-		if *rrs.Name == recordName && *rrs.SetIdentifier == setID {
+		if sameName(*rrs.Name, recordName) && *rrs.Type == recordType && stringValue(rrs.SetIdentifier) == setID {
 			return rrs, nil
 		}
 	}
-	return route53.ResourceRecordSet{}, fmt.Errorf("no ResourceRecordSets found for zoneID=%s recordName=%s recordType=%s setIdentifier=%s\n", zoneID, recordName, recordType, setID)
+	return route53.ResourceRecordSet{}, recordSetNotFoundError{zoneID: zoneID, name: recordName, recordType: recordType, setID: setID}
+}
+
+// recordSetNotFoundError is returned by getResourceRecordSet when no record set matches
+type recordSetNotFoundError struct {
+	zoneID     string
+	name       string
+	recordType string
+	setID      string
+}
+
+func (e recordSetNotFoundError) Error() string {
+	return fmt.Sprintf("no ResourceRecordSets found for zoneID=%s recordName=%s recordType=%s setIdentifier=%s", e.zoneID, e.name, e.recordType, e.setID)
+}
+
+// isNotFound reports whether err means the requested record set doesn't exist
+func isNotFound(err error) bool {
+	_, ok := err.(recordSetNotFoundError)
+	return ok
 }
 
 func usageFatal(message string) {
 	example := `
 	Usage: r53tool [flags] ipaddr <ipaddr2 ipaddr3 ...>
+	       (ipaddrs may also be given comma separated, e.g. 192.168.1.1,192.168.1.2)
+	       r53tool -cmd=rebalance [flags] setid=weight <setid2=weight2 ...>
+	       r53tool -cmd=cutover [flags] name=ipaddr1,ipaddr2 <name2=ipaddr3 ...>
 
 					required flags
 					--
-					-name="record.example.com.": record name
+					-name="record.example.com.": record name, repeat it or separate names by commas to add, del or swap on several names
 					-setid="": record set identifier
 
 					optional flags
 					--
-					-cmd="add" | "del" | "list" (defaults to add)
+					-cmd="add" | "del" | "swap" | "prune" | "update" | "alias" | "list" | "list-all" | "list-zones" | "list-global" | "find-ip" | "from-dns" | "diff" | "convert" | "simplify" | "rebalance" | "cutover" | "watch" | "normalize-ttl" | "import-zone" | "undo" | "recent" | "validate" (defaults to add)
 					-v=false: verbose
-					-region="us-east-1": AWS region
-					-type="A": record type (currently only A is supported)
+					-region="us-east-1": AWS region for credentials, defaults to $AWS_REGION or the profile's region when not given.
+					                     Route53 is global, its calls always go to the global endpoint whatever the region
+					-profile="": use credentials and region from this profile in ~/.aws
+					-mfa-token="": the MFA code for a -profile with an mfa_serial, asked for on the terminal when not given.
+					               The session obtained is cached in ~/.r53tool until it expires
+					-creds-file="": read credentials from this JSON file with AccessKeyId, SecretAccessKey and optional SessionToken
+					-type="A": record type, A | AAAA | CNAME | CAA (case-insensitive)
+					-output="xml": list output format, xml | table | values | yaml | json | zonefile | hash, json also reports errors as JSON,
+					                yaml and json use the -file batch schema, which has no form for alias record sets,
+					                hash prints a sha256 of each record set's contents that only changes when its answers do,
+					                awscli prints -dry-run and diff change batches as aws route53 change-resource-record-sets --change-batch JSON
+					-color="auto": color table output, auto (only on a terminal) | always | never, the NO_COLOR environment variable turns it off
+					-output-file="": write listings, diffs and explanations to this file instead of stdout
+					-multivalue=false: use multivalue answer routing (requires -setid)
+					-strict-setid=true: refuse a routing policy such as -weight without -setid, and create a simple record set
+					                    ignoring a -setid given without one, as Route53 rejects both
+					-zoneid="": hosted zone ID, skips looking up the zone by name
+					-zoneid-file="": read the hosted zone ID from this file, keeping it out of the process arguments
+					-all-setids=false: del removes the IPs from every set identifier of the name and type
+					-sort=false: sort listed values, and record sets by name, for deterministic output
+					-allow-missing=false: list prints an empty result instead of failing when the record set doesn't exist
+					-name-prefix="": list-all and list-global only show record sets whose name starts with this
+					-all-zones=false: find-ip searches every hosted zone of the account instead of the zone of -name
+					-only-type="": list-all, list-global, find-ip and normalize-ttl only process record sets of this type, e.g. A
+					-name-filter="": list-all and list-global only show record sets whose name contains this
+					-value-filter="": list-all and list-global only show record sets holding this value, e.g. an IP
+					-name-regex="": list-all and list-global only show record sets whose name matches this regular expression
+					-value-regex="": list-all and list-global only show record sets with a value matching this regular expression
+					-evaluate-target-health=false: update sets EvaluateTargetHealth of an alias record set
+					-weight=0: weight of the weighted record set created by convert or add -action=create (0-255)
+					-action="": how add treats the record set, create (CREATE, must not exist yet) | update (must exist) | upsert (UPSERT, created when missing), by default it must exist
+					-alias-target="": DNS name the record set created by alias points at, e.g. a load balancer
+					-alias-zoneid="": hosted zone ID of -alias-target, inferred for load balancers, CloudFront, S3 websites and Global Accelerator
+					-ttl=0: TTL for newly created record sets (defaults to 300), and the TTL normalize-ttl sets.
+					        add, del, swap, prune and update set it in the same atomic change as the values
+					-ttl-min=0: refuse changes leaving a record set with a TTL below this (0 disables the check)
+					-ttl-max=0: refuse changes leaving a record set with a TTL above this (0 disables the check)
+					-resolver="": DNS server used by from-dns and -resolve-cname (defaults to the system resolver)
+					-resolve-cname=false: refuse CNAME changes whose target doesn't resolve
+					-dry-run=false: print the changes instead of submitting them
+					-interval=30s: how often watch lists the record set
+					-verify=false: after add or swap check the zone's name servers answer with the new values
+					-explain=false: describe in plain English what the command will change, combine with -dry-run to only describe
+					-preflight="": before adding IPs check they answer, tcp | http
+					-preflight-port=80: port used by -preflight
+					-preflight-timeout=2s: how long -preflight waits for each IP
+					-preflight-warn=false: add IPs failing -preflight anyway after a warning
+					-max-values=400: refuse changes leaving a record set with more values than this
+					-min-healthy=0: refuse del, prune and batch changes shrinking a record set below this many values
+					-batch-size=500: maximum changes per Route53 request (at most 1000)
+					-file="": apply a JSON, YAML (.yaml/.yml) or CSV (.csv) batch of changes instead of a single -cmd
+					-input-format="": format of the -file batch, json | yaml | csv, defaults to the file extension
+					-import-apex=false: import-zone also replaces the apex SOA and NS record sets with the ones from the file
+					-yes=false: answer yes to confirmation prompts, needed to run simplify and undo non-interactively
+					-auto-confirm-tag="": skip confirmation prompts only for zones carrying this key=value tag, e.g. env=nonprod.
+					                      Zones whose tags can't be read are still prompted for
+					-delete-if-empty=false: delete the record set when del or prune removes its last value instead of failing
+					-force=false: let from-dns, convert and batch creates overwrite an existing record set with the same name, type and setid,
+					              and undo reverse changes to record sets modified since
+					-count=1: how many of the zone's last logged change batches undo reverses
+					-audit-log="": append every applied record set change to this file as JSON lines, recent lists them
+					-since=24h: how far back recent lists the changes in the -audit-log
+					-fail-fast=false: stop a batch at the first failed change instead of continuing
+					-parallel-zones=1: how many zones of a -file batch to submit concurrently
+					-zone-filter="": only process the -file changes for names in this zone, to roll out a batch zone by zone
+					-idempotent=false: skip change batches identical to one submitted within -idempotent-window
+					-idempotent-window=5m: how long a submitted change batch is remembered by -idempotent
+					-idempotency-token="": skip the run when one with this token already completed, e.g. a CI job ID
+					-baseline=false: remember what each change applied and warn when a record set was modified since, e.g. in the console
+					-backup=false: write the record sets del, swap, prune and other destructive changes modify to a timestamped -file batch first
+					-backup-dir="": directory -backup writes to, defaults to ~/.r53tool/backups
+					-webhook="": POST a JSON summary of every submitted change to this URL
+					-pushgateway="": push changes applied and failed, API calls and run duration to this Prometheus Pushgateway URL
+					-pushgateway-job="r53tool": job label of the metrics pushed to -pushgateway
+					-print-response=false: print the full response (id, status, submitted at, comment) of every submitted change in the -output format
+					-timeout=0: abort the whole run after this long, e.g. 2m
+					-zone-cache=false: remember zone IDs in ~/.r53tool/zones.json to skip looking them up
+					-user-agent="r53tool/VERSION": User-Agent sent with AWS API requests
+					-no-imds=false: never fetch credentials from the EC2 instance metadata service
 
 
 	This tool will update Route53 resource record sets by adding or removing IPs.
 	Currently the resource record sets needs to already exist.
 
-	Standard AWS environment variables are used to supply authentication credentials
+	Credentials are read from the standard AWS environment variables, then the shared
+	credentials file (~/.aws/credentials), then the EC2 instance metadata service
+
+	Exit codes: 1 error, 2 drift found by diff, 3 record set not found, 4 access denied,
+	5 timed out, 130 interrupted. With -output=json errors are printed to stdout as
+	{"error": "...", "code": "usage|error|not_found|access_denied|timeout|interrupted"}
 
 	Examples:
 	  # adding IPs
@@ -199,91 +1123,770 @@ func usageFatal(message string) {
 		# deleting IPs
 		r53tool -cmd=del -name=www.example.com -setid dc1 192.168.1.1 192.168.1.2
 
+		# allowing two certificate authorities to issue for a name
+		r53tool -cmd=add -name=example.com -type=CAA -action=upsert '0 issue "letsencrypt.org"' '0 issuewild "amazon.com"'
+
+		# adding an IP to several names, one change per zone
+		r53tool -cmd=add -name=www.example.com,api.example.com -setid dc1 192.168.1.3
+
+		# deleting an IP from every set identifier of a name
+		r53tool -cmd=del -all-setids -name=www.example.com 192.168.1.1
+
+		# replacing IPs in one atomic change
+		r53tool -cmd=swap -name=www.example.com -setid dc1 192.168.1.1=192.168.1.5
+
+		# lowering the TTL ahead of a migration in the same change as swapping an IP
+		r53tool -cmd=swap -name=www.example.com -setid dc1 -ttl=60 192.168.1.1=192.168.1.5
+
+		# removing every IP but the given ones, e.g. after an autoscaling group shrank
+		r53tool -cmd=prune -name=www.example.com -setid dc1 192.168.1.1 192.168.1.2
+
+		# keeping a copy of the record set to restore with -file if the change goes wrong
+		r53tool -cmd=del -backup -name=www.example.com -setid dc1 192.168.1.1
+
+		# pointing a name at a load balancer, its alias hosted zone is looked up
+		r53tool -cmd=alias -name=www.example.com -alias-target=my-elb-123.us-east-1.elb.amazonaws.com
+
+		# turning off target health evaluation for an alias record set
+		r53tool -cmd=update -name=www.example.com -setid dc1 -evaluate-target-health=false
+
 		# listing a resource record set
 		r53tool -cmd=list -name=www.example.com -setid dc1
 
+		# listing the dc1 A records of a zone
+		r53tool -cmd=list-all -name=example.com -type=A -setid dc1
+
+		# finding every A record of the account pointing at an IP
+		r53tool -cmd=list-global -type=A -value-filter=192.168.1.1 -output=table
+
+		# finding every record of the account with an internal 10.x value
+		r53tool -cmd=list-global -value-regex='^10\.' -output=table
+
+		# finding the record sets of a zone using IPs before reclaiming them
+		r53tool -cmd=find-ip -name=example.com -output=table 192.168.1.1 192.168.1.2
+
+		# listing the hosted zones of the account
+		r53tool -cmd=list-zones -output=table
+
+		# keeping the colored table header when paging
+		r53tool -cmd=list-zones -output=table -color=always | less -R
+
+		# creating a record set from the IPs a name currently resolves to
+		r53tool -cmd=from-dns -resolver=8.8.8.8 -name=www.example.com
+
+		# turning a simple record set into a weighted one
+		r53tool -cmd=convert -name=www.example.com -setid dc1 -weight 10
+
+		# setting the TTL of every A record set of a zone to 60
+		r53tool -cmd=normalize-ttl -name=example.com -only-type=A -ttl=60 -dry-run
+
+		# reversing the last two change batches applied to a zone, every applied batch is logged in ~/.r53tool
+		r53tool -cmd=undo -name=example.com -count=2
+
+		# listing the record sets changed in the last 6 hours, with every change logged to an audit log
+		r53tool -cmd=recent -since=6h -audit-log=/var/log/r53tool.jsonl
+
+		# turning a weighted record set back into a simple one
+		r53tool -cmd=simplify -name=www.example.com -setid dc1
+
+		# shifting traffic between three weighted record sets in one change
+		r53tool -cmd=rebalance -name=www.example.com blue=10 green=80 canary=10
+
+		# switching several names to new IPs in one atomic change
+		r53tool -cmd=cutover -setid dc1 www.example.com=192.168.2.1,192.168.2.2 api.example.com=192.168.2.3
+
+		# printing every change made to a record set until Ctrl-C
+		r53tool -cmd=watch -name=www.example.com -setid dc1 -interval=10s
+
+		# applying a batch of changes
+		r53tool -file=changes.json
+
+		# checking every entry of a batch file without contacting AWS, e.g. in CI
+		r53tool -cmd=validate -file=changes.json
+
+		# applying only the changes of a batch for one zone
+		r53tool -file=changes.json -zone-filter=example.com
+
+		# creating the record sets of a BIND zone file
+		r53tool -cmd=import-zone -name=example.com -file=db.example.com
+
+		# showing what a batch file would change, exits with 2 when the live record sets differ
+		r53tool -cmd=diff -file=desired.yaml
+
+		# writing the changes a diff found as a batch for aws route53 change-resource-record-sets
+		r53tool -cmd=diff -file=desired.yaml -output=awscli > batch.json
+
+		# hashing a record set to notice later changes without storing its contents
+		r53tool -cmd=list -name=www.example.com -output=hash
+
+	Batch files hold a JSON list of changes, action is add | del | upsert | create | delete
+	and defaults to upsert, which sets the record set to exactly the given values:
+		[{"action": "add", "name": "www.example.com", "setid": "dc1", "values": ["192.168.1.1"]}]
+	-output=yaml writes record sets in the same schema, so they can be exported, edited and re-applied:
+		r53tool -cmd=list -name=www.example.com -setid dc1 -output=yaml > rec.yaml
+		r53tool -file=rec.yaml
+	-output=zonefile writes BIND master file lines, which import-zone reads back:
+		r53tool -cmd=list-all -name=example.com -output=zonefile > db.example.com
+	CSV batch files have a header row with the columns action,name,type,setid,ttl,values,
+	multiple values are separated by semicolons:
+		action,name,setid,values
+		add,www.example.com,dc1,192.168.1.1;192.168.1.2
+
 `
+	if jsonErrors {
+		exitWithError(codeUsage, strings.TrimPrefix(message, "ERROR: "))
+	}
 	fmt.Println(message)
 	fmt.Println(example)
 	fmt.Println("version", version)
-	os.Exit(1)
+	os.Exit(exitError)
 }
 
 func main() {
-	recordName := flag.String("name", "", "record name")
+	var names nameList
+	flag.Var(&names, "name", "record name, repeat it or separate names by commas to add, del or swap on several names")
 	recordType := flag.String("type", "A", "record type")
 	setID := flag.String("setid", "", "record set identifier")
-	region := flag.String("region", defaultRegion, "AWS region")
+	region := flag.String("region", defaultRegion, "AWS region for credentials (defaults to $AWS_REGION, then the profile's region), Route53 calls always use the global endpoint")
+	profile := flag.String("profile", "", "use credentials and region from this profile in ~/.aws")
+	mfaToken := flag.String("mfa-token", "", "the MFA code for a -profile with an mfa_serial, asked for on the terminal when not given")
+	credsFilePath := flag.String("creds-file", "", "read credentials from this JSON file with AccessKeyId, SecretAccessKey and optional SessionToken")
 	verbose := flag.Bool("v", false, "verbose")
-	action := flag.String("cmd", "", "add | del | list - action")
+	action := flag.String("cmd", "", "action: "+commands)
+	zoneIDFlag := flag.String("zoneid", "", "hosted zone ID (skips the ListHostedZones lookup)")
+	zoneIDFile := flag.String("zoneid-file", "", "read the hosted zone ID from this file instead of -zoneid")
+	output := flag.String("output", outputXML, "list output format: xml | table | values | yaml | json | zonefile | hash | awscli (json also reports errors as JSON, awscli is for -dry-run and diff change batches)")
+	color := flag.String("color", colorAuto, "color table output: auto (only on a terminal) | always | never, NO_COLOR turns it off")
+	outputFile := flag.String("output-file", "", "write listings, diffs and explanations to this file instead of stdout")
+	idempotent := flag.Bool("idempotent", false, "skip change batches identical to one submitted within -idempotent-window")
+	dedupWindow := flag.Duration("idempotent-window", defaultDedupWindow, "how long a submitted change batch is remembered by -idempotent")
+	idempotencyToken := flag.String("idempotency-token", "", "skip the run when one with this token already completed, e.g. a CI job ID")
+	trackBaselines := flag.Bool("baseline", false, "remember what each change applied and warn when a record set was modified since, e.g. in the console")
+	backup := flag.Bool("backup", false, "write the record sets del, swap, prune and other destructive changes modify to a -file batch before changing them")
+	backupDir := flag.String("backup-dir", "", "directory -backup writes to (defaults to ~/.r53tool/backups)")
+	webhook := flag.String("webhook", "", "POST a JSON summary of every submitted change to this URL")
+	pushgateway := flag.String("pushgateway", "", "push the run's metrics to this Prometheus Pushgateway URL")
+	pushJob := flag.String("pushgateway-job", defaultPushJob, "job label of the metrics pushed to -pushgateway")
+	printResponse := flag.Bool("print-response", false, "print the full response of every submitted change in the -output format")
+	timeout := flag.Duration("timeout", 0, "abort the whole run after this long, e.g. 2m (0 means no limit)")
+	useZoneCache := flag.Bool("zone-cache", false, "remember zone IDs in ~/.r53tool/zones.json to skip the ListHostedZones lookup")
+	userAgent := flag.String("user-agent", defaultUserAgent, "User-Agent sent with AWS API requests")
+	noIMDS := flag.Bool("no-imds", false, "never fetch credentials from the EC2 instance metadata service")
+	multiValue := flag.Bool("multivalue", false, "use multivalue answer routing (requires -setid)")
+	strictSetID := flag.Bool("strict-setid", true, "refuse a routing policy without -setid and ignore a -setid without a routing policy when creating record sets")
+	allSetIDs := flag.Bool("all-setids", false, "del removes the IPs from every record set with the name and type, whatever the set identifier")
+	evaluateTargetHealth := flag.Bool("evaluate-target-health", false, "update sets EvaluateTargetHealth of an alias record set")
+	weight := flag.Int64("weight", 0, "weight of the weighted record set created by convert or add -action=create (0-255)")
+	aliasTarget := flag.String("alias-target", "", "DNS name the record set created by alias points at, e.g. a load balancer")
+	aliasZoneID := flag.String("alias-zoneid", "", "hosted zone ID of -alias-target, inferred for load balancers, CloudFront, S3 websites and Global Accelerator")
+	changeAction := flag.String("action", "", "how add treats the record set: create (must not exist yet) | update (must exist) | upsert (created when missing); by default it must exist")
+	ttl := flag.Int64("ttl", 0, "TTL for newly created record sets (defaults to 300) and set by normalize-ttl; add, del, swap, prune and update change it atomically with the values")
+	ttlMin := flag.Int64("ttl-min", 0, "refuse changes leaving a record set with a TTL below this (0 disables the check)")
+	ttlMax := flag.Int64("ttl-max", 0, "refuse changes leaving a record set with a TTL above this (0 disables the check)")
+	sortOutput := flag.Bool("sort", false, "sort listed values, and record sets by name, for deterministic output")
+	allowMissing := flag.Bool("allow-missing", false, "list prints an empty result instead of failing when the record set doesn't exist")
+	namePrefix := flag.String("name-prefix", "", "list-all and list-global only show record sets whose name starts with this")
+	onlyType := flag.String("only-type", "", "list-all, list-global, find-ip and normalize-ttl only process record sets of this type, e.g. A")
+	allZones := flag.Bool("all-zones", false, "find-ip searches every hosted zone of the account")
+	nameFilter := flag.String("name-filter", "", "list-all and list-global only show record sets whose name contains this")
+	valueFilter := flag.String("value-filter", "", "list-all and list-global only show record sets holding this value, e.g. an IP")
+	nameRegex := flag.String("name-regex", "", "list-all and list-global only show record sets whose name matches this regular expression")
+	valueRegex := flag.String("value-regex", "", "list-all and list-global only show record sets with a value matching this regular expression")
+	resolver := flag.String("resolver", "", "DNS server used by from-dns and -resolve-cname, e.g. 8.8.8.8 (defaults to the system resolver)")
+	resolveCNAME := flag.Bool("resolve-cname", false, "refuse CNAME changes whose target doesn't resolve")
+	explainFlag := flag.Bool("explain", false, "describe in plain English what the command will change before doing it")
+	interval := flag.Duration("interval", defaultWatchInterval, "how often watch lists the record set")
+	verify := flag.Bool("verify", false, "after add or swap check the zone's name servers answer with the new values")
+	dryRun := flag.Bool("dry-run", false, "print the changes instead of submitting them")
+	batchFile := flag.String("file", "", "apply a JSON, YAML (.yaml/.yml) or CSV (.csv) batch of changes instead of a single -cmd")
+	importApex := flag.Bool("import-apex", false, "import-zone also replaces the apex SOA and NS record sets with the ones from the file")
+	parallelZones := flag.Int("parallel-zones", 1, "how many zones of a -file batch to submit concurrently")
+	zoneFilter := flag.String("zone-filter", "", "only process the -file changes for names in this zone")
+	assumeYes := flag.Bool("yes", false, "answer yes to confirmation prompts, needed to run simplify and undo non-interactively")
+	autoConfirmTag := flag.String("auto-confirm-tag", "", "skip confirmation prompts only for zones carrying this key=value tag, e.g. env=nonprod")
+	deleteIfEmpty := flag.Bool("delete-if-empty", false, "delete the record set when del or prune removes its last value instead of failing")
+	force := flag.Bool("force", false, "let commands creating record sets overwrite an existing one with the same name, type and setid, and undo reverse record sets modified since")
+	undoCount := flag.Int("count", 1, "how many of the zone's last logged change batches undo reverses")
+	auditLog := flag.String("audit-log", "", "append every applied record set change to this file as JSON lines, recent lists them")
+	since := flag.Duration("since", defaultRecentWindow, "how far back recent lists the changes in the -audit-log")
+	inputFormat := flag.String("input-format", "", "format of the -file batch: json | yaml | csv (defaults to the file extension)")
+	failFast := flag.Bool("fail-fast", false, "stop a batch at the first failed change instead of continuing")
+	preflightMode := flag.String("preflight", "", "before adding IPs check they answer: tcp | http")
+	preflightPort := flag.Int("preflight-port", defaultPreflightPort, "port used by -preflight")
+	preflightTimeout := flag.Duration("preflight-timeout", defaultPreflightTimeout, "how long -preflight waits for each IP")
+	preflightWarn := flag.Bool("preflight-warn", false, "add IPs failing -preflight anyway after a warning")
+	minHealthy := flag.Int("min-healthy", 0, "refuse changes shrinking a record set below this many values (0 disables the check)")
+	maxValues := flag.Int("max-values", defaultMaxValues, "refuse changes leaving a record set with more values than this (0 disables the check)")
+	batchSize := flag.Int("batch-size", defaultBatchSize, "maximum changes per Route53 request (at most 1000)")
 	flag.Parse()
+	start := time.Now()
+	recordName := new(string)
+	if len(names) > 0 {
+		*recordName = names[0]
+	}
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
 	c := &cli{
 		log: log.New(os.Stderr, "", log.LstdFlags),
+		ctx: context.Background(),
+		out: os.Stdout,
+	}
+
+	jsonErrors = *output == outputJSON
+	colorMode = *color
+
+	ips := splitValues(flag.Args())
+	opts := options{
+		action:       *action,
+		names:        names,
+		recordType:   *recordType,
+		setID:        *setID,
+		values:       ips,
+		args:         flag.Args(),
+		setFlags:     setFlags,
+		batchFile:    *batchFile,
+		zoneID:       *zoneIDFlag,
+		zoneIDFile:   *zoneIDFile,
+		output:       *output,
+		color:        *color,
+		allZones:     *allZones,
+		allSetIDs:    *allSetIDs,
+		verify:       *verify,
+		explain:      *explainFlag,
+		interval:     *interval,
+		dryRun:       *dryRun,
+		changeAction: *changeAction,
+		onlyType:     *onlyType,
+		aliasTarget:  *aliasTarget,
+		credsFile:    *credsFilePath,
+		profile:      *profile,
+		mfaToken:     *mfaToken,
+		multiValue:   *multiValue,
+		ttl:          *ttl,
+		ttlMin:       *ttlMin,
+		ttlMax:       *ttlMax,
+		weight:       *weight,
+		idempotent:   *idempotent,
+		dedupWindow:  *dedupWindow,
+		token:        *idempotencyToken,
+		preflight:    *preflightMode,
+		minHealthy:   *minHealthy,
+		maxValues:    *maxValues,
+		inputFormat:  *inputFormat,
+		nameRegex:    *nameRegex,
+		valueRegex:   *valueRegex,
+		parallel:     *parallelZones,
+		batchSize:    *batchSize,
+		deleteEmpty:  *deleteIfEmpty,
+		undoCount:    *undoCount,
+		strictSetID:  *strictSetID,
+		zoneFilter:   *zoneFilter,
+		autoConfirm:  *autoConfirmTag,
+		auditLog:     *auditLog,
+		since:        *since,
+	}
+	if err := opts.validate(); err != nil {
+		usageFatal("ERROR: " + err.Error())
+	}
+
+	var swaps []swapPair
+	if *action == "swap" {
+		swaps, _ = parseSwapPairs(ips)
+	}
+	*recordType = normalizeType(*recordType)
+	for i := range ips {
+		ips[i] = canonicalValue(*recordType, ips[i])
+	}
+
+	if *zoneIDFile != "" {
+		id, err := readZoneIDFile(*zoneIDFile)
+		if err != nil {
+			usageFatal("ERROR: reading -zoneid-file: " + err.Error())
+		}
+		*zoneIDFlag = id
 	}
 
-	ips := flag.Args()
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			c.fatal(fmt.Errorf("creating -output-file %w", err))
+		}
+		defer f.Close()
+		c.out = f
+	}
+
+	c.verbose = *verbose
+	c.multiValue = *multiValue
+	c.dryRun = *dryRun
+	c.batchSize = *batchSize
+	c.failFast = *failFast
+	c.force = *force
+	c.deleteIfEmpty = *deleteIfEmpty
+	c.strictSetID = *strictSetID
+	c.assumeYes = *assumeYes
+	if *autoConfirmTag != "" {
+		c.autoConfirmKey, c.autoConfirmValue, _ = parseTag(*autoConfirmTag)
+	}
+	c.resolveCNAME = *resolveCNAME
+	c.resolver = *resolver
+	c.parallelZones = *parallelZones
+	c.maxValues = *maxValues
+	c.minHealthy = *minHealthy
+	c.ttlMin = *ttlMin
+	c.ttlMax = *ttlMax
 	switch *action {
-	case "add", "del":
-		if len(ips) == 0 {
-			usageFatal(fmt.Sprintf("ERROR: %s needs one or more ipaddrs", *action))
+	case "add", "del", "swap", "prune", "update":
+		if *batchFile == "" {
+			c.ttl = *ttl
 		}
-	case "list":
-		if len(ips) != 0 {
-			usageFatal("ERROR: list does not take any ipaddrs")
+	}
+	if setFlags["evaluate-target-health"] {
+		c.evaluateTargetHealth = evaluateTargetHealth
+	}
+	c.webhook = *webhook
+	c.pushgateway = *pushgateway
+	c.trackBaselines = *trackBaselines
+	if *backup {
+		c.backupDir = *backupDir
+		if c.backupDir == "" {
+			c.backupDir = defaultBackupDir()
 		}
-	default:
-		usageFatal("ERROR: supported commands are add|del|list")
+		c.backupFormat = *output
+	}
+	c.pushJob = *pushJob
+	c.start = start
+	defer c.pushMetrics()
+	if *printResponse {
+		c.responseFormat = *output
+	}
+	if *output == outputAWSCLI {
+		c.changeFormat = outputAWSCLI
+	}
+	c.preflight = preflight{mode: *preflightMode, port: *preflightPort, timeout: *preflightTimeout, warnOnly: *preflightWarn}
+	if *idempotent {
+		c.dedupWindow = *dedupWindow
+	}
+	if *idempotencyToken != "" {
+		c.idempotencyToken = *idempotencyToken
+		applied, err := c.tokenApplied()
+		if err != nil {
+			c.fatal(err)
+		}
+		if applied {
+			return
+		}
+		defer c.recordToken()
 	}
 
-	switch *recordType {
-	case "A":
-	default:
-		usageFatal("ERROR: only operations on A records are currently supported")
+	if *timeout > 0 {
+		defer c.startTimeout(*timeout)()
+	}
+	c.watchSignals()
+
+	c.auditLog = *auditLog
+	if *action == "recent" {
+		// recent only reads the local audit log, no credentials are needed
+		if err := c.printRecent(*since, *output); err != nil {
+			c.fatal(err)
+		}
+		return
+	}
+	if *action == "validate" {
+		// validate only checks the batch file locally, no credentials are needed
+		entries, err := readBatchFile(*batchFile, *inputFormat)
+		if err != nil {
+			c.fatal(fmt.Errorf("reading batch file %w", err))
+		}
+		errs := c.checkEntries(entries)
+		for _, err := range errs {
+			c.log.Println("ERROR", err)
+		}
+		if len(errs) > 0 {
+			c.fatal(fmt.Errorf("%d of %d entries in %s are invalid", len(errs), len(entries), *batchFile))
+		}
+		c.log.Printf("all %d entries in %s are valid\n", len(entries), *batchFile)
+		return
 	}
 
-	auth, err := aws.EnvCreds()
+	mfa, err := loadMFAProfile(awsConfigPath(), *profile)
 	if err != nil {
-		c.log.Fatal("ERROR setting auth ", err)
+		c.fatal(fmt.Errorf("reading profile %s %w", *profile, err))
+	}
+	if *mfaToken != "" && mfa.serial == "" {
+		c.fatal(fmt.Errorf("-mfa-token is given but profile %s has no mfa_serial", *profile))
+	}
+	var auth aws.CredentialsProvider
+	switch {
+	case *credsFilePath != "":
+		auth, err = fileCreds(*credsFilePath)
+	case mfa.serial != "":
+		auth, err = c.mfaCreds(mfa, *mfaToken, newHTTPClient(*userAgent))
+	default:
+		auth, err = resolveCreds(*profile, !*noIMDS)
+	}
+	if err != nil {
+		c.fatal(fmt.Errorf("setting auth %w", err))
+	}
 
+	// Route53 is a global service, the region is only kept for the credential providers that need one
+	c.region = resolveRegion(*region, setFlags["region"], *profile)
+	if c.verbose && c.region != route53SigningRegion {
+		c.log.Printf("using the global Route53 endpoint, region=%s is not used for Route53 calls\n", c.region)
 	}
+	c.r53 = route53.New(auth, route53SigningRegion, newHTTPClient(*userAgent))
 
-	c.verbose = *verbose
+	if *useZoneCache {
+		if c.zoneCache, err = loadZoneCache(); err != nil {
+			c.log.Println("WARNING ignoring zone cache", err)
+		}
+	}
 
-	c.r53 = route53.New(auth, *region, http.DefaultClient)
+	if *action == "import-zone" {
+		zoneName := normalizeName(*recordName)
+		err := c.withZone(zoneName, *zoneIDFlag, func(zone zoneRef) error {
+			return c.importZone(zone.id, zoneName, *batchFile, *importApex)
+		})
+		if err != nil {
+			c.fatal(fmt.Errorf("importing zone %w", err))
+		}
+		return
+	}
 
-	if !strings.HasSuffix(*recordName, ".") {
-		*recordName += "."
+	if *batchFile != "" {
+		entries, err := readBatchFile(*batchFile, *inputFormat)
+		if err != nil {
+			c.fatal(fmt.Errorf("reading batch file %w", err))
+		}
+		if *zoneFilter != "" {
+			var skipped int
+			entries, skipped = c.filterZone(entries, *zoneFilter, *zoneIDFlag == "")
+			c.log.Printf("skipped %d of %d change(s) outside -zone-filter=%s\n", skipped, len(entries)+skipped, normalizeName(*zoneFilter))
+		}
+		if *action == "diff" {
+			diffs, err := c.diffEntries(entries, *zoneIDFlag)
+			if err != nil {
+				c.fatal(fmt.Errorf("comparing record sets %w", err))
+			}
+			drifted := 0
+			if *output == outputAWSCLI {
+				drifted, err = c.printDiffBatches(c.out, diffs)
+			} else {
+				drifted = printDiffs(c.out, diffs)
+			}
+			if err != nil {
+				c.fatal(fmt.Errorf("writing output %w", err))
+			}
+			if drifted > 0 {
+				c.pushMetrics()
+				os.Exit(exitDrift)
+			}
+			return
+		}
+		result := c.runBatch(entries, *zoneIDFlag)
+		for _, err := range result.errs {
+			c.log.Println("ERROR", err)
+		}
+		c.log.Println(result.summary(time.Since(start)))
+		if result.interrupted {
+			c.reportInterrupted(result.pending)
+			c.pushMetrics()
+			os.Exit(exitInterrupted)
+		}
+		if len(result.errs) > 0 {
+			c.fatal(fmt.Errorf("%d change(s) failed, %d applied, %d unchanged", len(result.errs), result.applied, result.skipped))
+		}
+		return
 	}
 
-	zoneID, err := c.zoneIDByName(*recordName)
-	if err != nil {
-		log.Fatal("ERROR getting zoneid ", err)
+	filter := recordSetFilter{namePrefix: *namePrefix, nameContains: *nameFilter, setID: *setID}
+	if setFlags["type"] {
+		filter.recordType = *recordType
+	}
+	if *onlyType != "" {
+		filter.recordType = normalizeType(*onlyType)
+	}
+	if *valueFilter != "" {
+		filter.values = []string{*valueFilter}
+	}
+	if *action == "find-ip" {
+		filter.values = ips
+	}
+	if *nameRegex != "" {
+		filter.nameRegex = regexp.MustCompile(*nameRegex)
+	}
+	if *valueRegex != "" {
+		filter.valueRegex = regexp.MustCompile(*valueRegex)
 	}
 
-	rrs, err := c.getResourceRecordSet(zoneID, *recordName, *recordType, *setID)
-	if err != nil {
-		c.log.Fatal("ERROR getting resource record set ", err)
+	if *action == "list-global" || (*action == "find-ip" && *allZones) {
+		sets, err := c.listAllZones(filter)
+		if err != nil {
+			c.fatal(err)
+		}
+		if len(sets) == 0 && *action == "find-ip" {
+			c.log.Printf("no record set in any zone holds %s\n", strings.Join(ips, ","))
+		}
+		if filter.usesRegex() {
+			c.log.Printf("%d record set(s) matched\n", len(sets))
+		}
+		if *sortOutput {
+			sets = sortRecordSets(sets)
+		}
+		if err := printRecordSets(c.out, *output, sets...); err != nil {
+			c.fatal(fmt.Errorf("writing output %w", err))
+		}
+		return
 	}
 
-	if c.verbose {
-		printResourceRecordSet(rrs)
+	if *action == "list-zones" {
+		zones, err := c.listHostedZones()
+		if err != nil {
+			c.fatal(fmt.Errorf("listing hosted zones %w", err))
+		}
+		if err := printZones(c.out, *output, zones); err != nil {
+			c.fatal(fmt.Errorf("writing output %w", err))
+		}
+		return
 	}
 
-	switch *action {
-	case "add":
-		err = c.addToARecordResourceRecordSet(zoneID, rrs, ips...)
+	*recordName = normalizeName(*recordName)
+
+	if *action == "cutover" {
+		targets, err := parseCutover(flag.Args(), *recordType)
 		if err != nil {
-			c.log.Fatal("ERROR adding to resource record set ", err)
+			c.fatal(err)
 		}
-	case "del":
-		err = c.delFromARecordResourceRecordSet(zoneID, rrs, ips...)
+		result, err := c.cutover(*zoneIDFlag, *recordType, *setID, targets)
+		for _, err := range result.errs {
+			c.log.Println("ERROR", err)
+		}
+		c.log.Println(result.summary(time.Since(start)))
 		if err != nil {
-			c.log.Fatal("ERROR deleting from resource record set ", err)
+			c.fatal(err)
 		}
-	case "list":
-		printResourceRecordSet(rrs)
-	default:
-		usageFatal("ERROR action not implemented " + *action)
+		return
 	}
 
+	if len(names) > 1 {
+		if *action == "add" {
+			if ips, err = c.preflightIPs(ips); err != nil {
+				c.fatal(err)
+			}
+		}
+		result, err := c.changeNames(*action, names, *zoneIDFlag, *recordType, *setID, ips, swaps)
+		c.log.Println(result.summary(time.Since(start)))
+		if err != nil {
+			c.fatal(err)
+		}
+		return
+	}
+
+	err = c.withZone(*recordName, *zoneIDFlag, func(zone zoneRef) error {
+		zoneID := zone.id
+		if *action == "normalize-ttl" {
+			return c.normalizeTTL(zone, filter, *ttl)
+		}
+		if *action == "undo" {
+			return c.undo(zone, *undoCount)
+		}
+
+		if *action == "list-all" || *action == "find-ip" {
+			sets, err := c.listResourceRecordSets(zoneID, filter)
+			if err != nil {
+				return fmt.Errorf("listing resource record sets %w", err)
+			}
+			if len(sets) == 0 && *action == "find-ip" {
+				c.log.Printf("no record set in zoneID=%s holds %s\n", zoneID, strings.Join(ips, ","))
+			}
+			if filter.usesRegex() {
+				c.log.Printf("%d record set(s) matched in zoneID=%s\n", len(sets), zoneID)
+			}
+			if *sortOutput {
+				sets = sortRecordSets(sets)
+			}
+			if err := printRecordSets(c.out, *output, sets...); err != nil {
+				return fmt.Errorf("writing output %w", err)
+			}
+			return nil
+		}
+
+		if *action == "from-dns" {
+			if *setID != "" && !*multiValue {
+				return fmt.Errorf("from-dns only creates simple or -multivalue record sets, a -setid needs -multivalue")
+			}
+			rrs := newResourceRecordSet(*recordName, *recordType, *setID, *ttl)
+			if *explainFlag {
+				fmt.Fprintln(c.out, explain(*action, rrs, zone, nil, 0))
+			}
+			if err := c.createFromDNS(zoneID, rrs, *resolver); err != nil {
+				return fmt.Errorf("creating record set from DNS %w", err)
+			}
+			return nil
+		}
+
+		if *action == "convert" {
+			simple, err := c.getResourceRecordSet(zoneID, *recordName, *recordType, "")
+			if err != nil {
+				return fmt.Errorf("getting simple resource record set %w", err)
+			}
+			if *explainFlag {
+				fmt.Fprintln(c.out, explain(*action, simple, zone, []string{"setid=" + *setID, fmt.Sprintf("weight=%d", *weight)}, 0))
+			}
+			if err := c.convertToWeighted(zoneID, simple, *setID, *weight); err != nil {
+				return fmt.Errorf("converting resource record set %w", err)
+			}
+			return nil
+		}
+
+		if *action == "rebalance" {
+			weights, err := parseSetWeights(ips)
+			if err != nil {
+				return err
+			}
+			return c.rebalance(zoneID, *recordName, *recordType, weights)
+		}
+
+		if *action == "watch" {
+			return c.watch(zoneID, *recordName, *recordType, *setID, *interval, *output)
+		}
+
+		if *action == "simplify" {
+			routed, err := c.getResourceRecordSet(zoneID, *recordName, *recordType, *setID)
+			if err != nil {
+				return fmt.Errorf("getting resource record set %w", err)
+			}
+			if *explainFlag {
+				fmt.Fprintln(c.out, explain(*action, routed, zone, nil, 0))
+			}
+			if err := c.simplify(zoneID, routed); err != nil {
+				return fmt.Errorf("simplifying resource record set %w", err)
+			}
+			return nil
+		}
+
+		if *allSetIDs {
+			sets, err := c.recordSetsByName(zoneID, *recordName, *recordType)
+			if err != nil {
+				return fmt.Errorf("getting resource record sets %w", err)
+			}
+			if len(sets) == 0 {
+				return fmt.Errorf("no ResourceRecordSets found for zoneID=%s recordName=%s recordType=%s", zoneID, *recordName, *recordType)
+			}
+			if err := c.delFromAllSetIDs(zoneID, sets, ips...); err != nil {
+				return fmt.Errorf("deleting from resource record sets %w", err)
+			}
+			return nil
+		}
+
+		if *action == "alias" {
+			aliasZone := *aliasZoneID
+			if aliasZone == "" {
+				var err error
+				if aliasZone, err = aliasHostedZoneID(*aliasTarget); err != nil {
+					return err
+				}
+			}
+			rrs := newResourceRecordSet(*recordName, *recordType, *setID, 0)
+			if setFlags["weight"] {
+				rrs.Weight = aws.Long(*weight)
+			}
+			if err := c.setAlias(zoneID, rrs, *aliasTarget, aliasZone); err != nil {
+				return fmt.Errorf("setting alias record set %w", err)
+			}
+			return nil
+		}
+
+		rrs, err := c.getResourceRecordSet(zoneID, *recordName, *recordType, *setID)
+		if *action == "add" {
+			created := newResourceRecordSet(*recordName, *recordType, *setID, *ttl)
+			if setFlags["weight"] {
+				created.Weight = aws.Long(*weight)
+			}
+			if done, err := c.createForAction(zoneID, *changeAction, created, err, ips); done || err != nil {
+				return err
+			}
+		}
+		if err != nil && isNotFound(err) && *allowMissing && *action == "list" {
+			if c.verbose {
+				c.log.Println(err)
+			}
+			if err := printRecordSets(c.out, *output); err != nil {
+				return fmt.Errorf("writing output %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting resource record set %w", err)
+		}
+
+		if c.verbose {
+			printResourceRecordSet(os.Stdout, rrs)
+		}
+
+		if *explainFlag && *action != "list" {
+			values := ips
+			if *action == "swap" {
+				values = nil
+				for _, p := range swaps {
+					values = append(values, p.old+" with "+p.new)
+				}
+			}
+			fmt.Fprintln(c.out, explain(*action, rrs, zone, values, c.ttl))
+		}
+
+		// expected are the values -verify checks the zone's name servers answer with afterwards
+		var expected []string
+		switch *action {
+		case "add":
+			ips, err := c.preflightIPs(ips)
+			if err != nil {
+				return err
+			}
+			if err := c.addToARecordResourceRecordSet(zoneID, rrs, ips...); err != nil {
+				return fmt.Errorf("adding to resource record set %w", err)
+			}
+			expected = ips
+		case "del":
+			c.backup(rrs)
+			if err := c.delFromARecordResourceRecordSet(zoneID, rrs, ips...); err != nil {
+				return fmt.Errorf("deleting from resource record set %w", err)
+			}
+		case "swap":
+			c.backup(rrs)
+			if err := c.swapValues(zoneID, rrs, swaps); err != nil {
+				return fmt.Errorf("swapping values in resource record set %w", err)
+			}
+			for _, p := range swaps {
+				expected = append(expected, p.new)
+			}
+		case "prune":
+			c.backup(rrs)
+			if err := c.pruneValues(zoneID, rrs, ips...); err != nil {
+				return fmt.Errorf("pruning resource record set %w", err)
+			}
+		case "update":
+			if err := c.upsertResourceRecordSet(zoneID, rrs, copyResourceRecordSet(rrs)); err != nil {
+				return fmt.Errorf("updating resource record set %w", err)
+			}
+		case "list":
+			if *sortOutput {
+				rrs = sortRecordSets([]route53.ResourceRecordSet{rrs})[0]
+			}
+			if err := printRecordSets(c.out, *output, rrs); err != nil {
+				return fmt.Errorf("writing output %w", err)
+			}
+		default:
+			return fmt.Errorf("action not implemented %s", *action)
+		}
+		if *verify && !c.dryRun {
+			return c.verifyResolves(zoneID, *recordName, *recordType, expected)
+		}
+		return nil
+	})
+	if errors.Is(err, errInterrupted) {
+		c.reportInterrupted(0)
+	}
+	if err != nil {
+		c.fatal(err)
+	}
 }
@@ -0,0 +1,779 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// fakeRoute53 is an in-memory Route53 holding the record sets of its zones, it applies submitted changes
+type fakeRoute53 struct {
+	// mu guards the fake, zones of a batch may be submitted concurrently
+	mu    sync.Mutex
+	zones map[string]string
+	sets  map[string][]route53.ResourceRecordSet
+	tags  map[string]map[string]string
+	// changeErrs are returned by successive ChangeResourceRecordSets calls, a nil entry lets the call succeed
+	changeErrs []error
+	// zoneErrs fails every ChangeResourceRecordSets call for a zone, tagsErr every ListTagsForResource call
+	zoneErrs map[string]error
+	tagsErr  error
+	// requests holds every ChangeResourceRecordSets request, including failed ones
+	requests         []*route53.ChangeResourceRecordSetsRequest
+	listZonesCalls   int
+	listRecordsCalls int
+	getZoneCalls     int
+	// pageSize limits how many record sets a ListResourceRecordSets page holds, 0 for all of them
+	pageSize int
+	// onListRecords is called before each ListResourceRecordSets page is returned, if set
+	onListRecords func()
+}
+
+// newFakeRoute53 returns a fake with the zones named by ID, e.g. {"Z1": "example.com."}
+func newFakeRoute53(zones map[string]string) *fakeRoute53 {
+	return &fakeRoute53{
+		zones: zones,
+		sets:  make(map[string][]route53.ResourceRecordSet),
+		tags:  make(map[string]map[string]string),
+	}
+}
+
+// add puts record sets into a zone as they'd be returned by ListResourceRecordSets
+func (f *fakeRoute53) add(zoneID string, sets ...route53.ResourceRecordSet) {
+	f.sets[zoneID] = append(f.sets[zoneID], sets...)
+}
+
+// find returns the index of the record set of the zone with the name, type and set identifier of rrs, -1 if none
+func (f *fakeRoute53) find(zoneID string, rrs route53.ResourceRecordSet) int {
+	for i, existing := range f.sets[zoneID] {
+		if sameName(stringValue(existing.Name), stringValue(rrs.Name)) && stringValue(existing.Type) == stringValue(rrs.Type) &&
+			stringValue(existing.SetIdentifier) == stringValue(rrs.SetIdentifier) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSets(req *route53.ChangeResourceRecordSetsRequest) (*route53.ChangeResourceRecordSetsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if err := f.zoneErrs[stringValue(req.HostedZoneID)]; err != nil {
+		return nil, err
+	}
+	if len(f.changeErrs) > 0 {
+		err := f.changeErrs[0]
+		f.changeErrs = f.changeErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	zoneID := stringValue(req.HostedZoneID)
+	for _, change := range req.ChangeBatch.Changes {
+		rrs := *change.ResourceRecordSet
+		i := f.find(zoneID, rrs)
+		switch stringValue(change.Action) {
+		case "DELETE":
+			if i < 0 {
+				return nil, aws.APIError{StatusCode: 400, Code: "InvalidChangeBatch", Message: "not found"}
+			}
+			f.sets[zoneID] = append(f.sets[zoneID][:i], f.sets[zoneID][i+1:]...)
+		case "CREATE":
+			if i >= 0 {
+				return nil, aws.APIError{StatusCode: 400, Code: "InvalidChangeBatch", Message: "already exists"}
+			}
+			f.sets[zoneID] = append(f.sets[zoneID], rrs)
+		default:
+			if i >= 0 {
+				f.sets[zoneID][i] = rrs
+			} else {
+				f.sets[zoneID] = append(f.sets[zoneID], rrs)
+			}
+		}
+	}
+	id := fmt.Sprintf("/change/C%d", len(f.requests))
+	return &route53.ChangeResourceRecordSetsResponse{ChangeInfo: &route53.ChangeInfo{ID: aws.String(id), Status: aws.String("PENDING")}}, nil
+}
+
+func (f *fakeRoute53) GetHostedZone(req *route53.GetHostedZoneRequest) (*route53.GetHostedZoneResponse, error) {
+	f.getZoneCalls++
+	id := stringValue(req.ID)
+	name, exists := f.zones[id]
+	if !exists {
+		return nil, aws.APIError{StatusCode: 404, Code: "NoSuchHostedZone"}
+	}
+	return &route53.GetHostedZoneResponse{
+		HostedZone:    &route53.HostedZone{ID: aws.String("/hostedzone/" + id), Name: aws.String(name)},
+		DelegationSet: &route53.DelegationSet{NameServers: []string{"ns-1.awsdns-01.org"}},
+	}, nil
+}
+
+func (f *fakeRoute53) ListHostedZones(req *route53.ListHostedZonesRequest) (*route53.ListHostedZonesResponse, error) {
+	f.listZonesCalls++
+	resp := &route53.ListHostedZonesResponse{IsTruncated: aws.Boolean(false)}
+	for id, name := range f.zones {
+		resp.HostedZones = append(resp.HostedZones, route53.HostedZone{ID: aws.String("/hostedzone/" + id), Name: aws.String(name)})
+	}
+	// listed by name like the record sets, see listOrder
+	sort.Slice(resp.HostedZones, func(i, j int) bool {
+		return listOrder(route53.ResourceRecordSet{Name: resp.HostedZones[i].Name}) < listOrder(route53.ResourceRecordSet{Name: resp.HostedZones[j].Name})
+	})
+	return resp, nil
+}
+
+// ListResourceRecordSets returns the record sets from StartRecordName and StartRecordType on,
+// in the order Route53 uses: by name with its labels reversed, then by type
+func (f *fakeRoute53) ListResourceRecordSets(req *route53.ListResourceRecordSetsRequest) (*route53.ListResourceRecordSetsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listRecordsCalls++
+	if f.onListRecords != nil {
+		f.onListRecords()
+	}
+	zoneID := stringValue(req.HostedZoneID)
+	if _, exists := f.zones[zoneID]; !exists {
+		return nil, aws.APIError{StatusCode: 404, Code: "NoSuchHostedZone"}
+	}
+	sets := make([]route53.ResourceRecordSet, len(f.sets[zoneID]))
+	copy(sets, f.sets[zoneID])
+	sort.SliceStable(sets, func(i, j int) bool { return listOrder(sets[i]) < listOrder(sets[j]) })
+	resp := &route53.ListResourceRecordSetsResponse{IsTruncated: aws.Boolean(false)}
+	start := route53.ResourceRecordSet{Name: req.StartRecordName, Type: req.StartRecordType, SetIdentifier: req.StartRecordIdentifier}
+	for _, rrs := range sets {
+		if req.StartRecordName != nil && listOrder(rrs) < listOrder(start) {
+			continue
+		}
+		if f.pageSize > 0 && len(resp.ResourceRecordSets) == f.pageSize {
+			resp.IsTruncated = aws.Boolean(true)
+			resp.NextRecordName, resp.NextRecordType, resp.NextRecordIdentifier = rrs.Name, rrs.Type, rrs.SetIdentifier
+			break
+		}
+		resp.ResourceRecordSets = append(resp.ResourceRecordSets, rrs)
+	}
+	return resp, nil
+}
+
+// listOrder is the position of a record set in listings, e.g. "com.example.www A dc1" for www.example.com.
+func listOrder(rrs route53.ResourceRecordSet) string {
+	labels := strings.Split(strings.ToLower(unescapeName(normalizeName(stringValue(rrs.Name)))), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Trim(strings.Join(labels, "."), ".") + " " + stringValue(rrs.Type) + " " + stringValue(rrs.SetIdentifier)
+}
+
+func (f *fakeRoute53) ListTagsForResource(req *route53.ListTagsForResourceRequest) (*route53.ListTagsForResourceResponse, error) {
+	if f.tagsErr != nil {
+		return nil, f.tagsErr
+	}
+	set := &route53.ResourceTagSet{ResourceID: req.ResourceID, ResourceType: req.ResourceType}
+	for key, value := range f.tags[stringValue(req.ResourceID)] {
+		set.Tags = append(set.Tags, route53.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return &route53.ListTagsForResourceResponse{ResourceTagSet: set}, nil
+}
+
+// newTestCLI returns a cli using the fake, its log and output are collected in the returned buffers.
+// HOME is pointed at a temporary directory so state files stay out of the real one.
+func newTestCLI(t *testing.T, fake *fakeRoute53) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	logs := &bytes.Buffer{}
+	out := &bytes.Buffer{}
+	c := &cli{
+		r53:       fake,
+		log:       log.New(logs, "", 0),
+		out:       out,
+		ctx:       context.Background(),
+		batchSize: defaultBatchSize,
+		maxValues: defaultMaxValues,
+	}
+	return c, logs, out
+}
+
+// testRecordSet builds a simple record set with the values
+func testRecordSet(name, recordType string, ttl int64, values ...string) route53.ResourceRecordSet {
+	rrs := route53.ResourceRecordSet{Name: aws.String(name), Type: aws.String(recordType), TTL: aws.Long(ttl)}
+	for _, v := range values {
+		rrs.ResourceRecords = append(rrs.ResourceRecords, route53.ResourceRecord{Value: aws.String(v)})
+	}
+	return rrs
+}
+
+// changesOf lists the changes of the submitted requests as "ACTION name type", in order
+func changesOf(requests []*route53.ChangeResourceRecordSetsRequest) []string {
+	var changes []string
+	for _, req := range requests {
+		for _, change := range req.ChangeBatch.Changes {
+			rrs := change.ResourceRecordSet
+			changes = append(changes, strings.Join([]string{stringValue(change.Action), stringValue(rrs.Name), stringValue(rrs.Type)}, " "))
+		}
+	}
+	return changes
+}
+
+func TestSubmitChangesSplitsBatches(t *testing.T) {
+	tests := []struct {
+		batchSize int
+		calls     []int
+	}{
+		{maxBatchSize, []int{1000, 1000, 500}},
+		{defaultBatchSize, []int{500, 500, 500, 500, 500}},
+	}
+	for _, tt := range tests {
+		fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+		c, _, _ := newTestCLI(t, fake)
+		c.batchSize = tt.batchSize
+		var changes []route53.Change
+		for i := 0; i < 2500; i++ {
+			rrs := testRecordSet(fmt.Sprintf("host%d.example.com.", i), "A", 300, "192.0.2.1")
+			changes = append(changes, route53.Change{Action: aws.String("CREATE"), ResourceRecordSet: &rrs})
+		}
+		if err := c.submitChanges("Z1", changes); err != nil {
+			t.Fatal(err)
+		}
+		var calls []int
+		for _, req := range fake.requests {
+			calls = append(calls, len(req.ChangeBatch.Changes))
+		}
+		if !reflect.DeepEqual(calls, tt.calls) {
+			t.Errorf("batchSize=%d: ChangeResourceRecordSets calls with %v changes, want %v", tt.batchSize, calls, tt.calls)
+		}
+		if c.submittedChanges != 2500 || len(c.changeIDs) != len(tt.calls) {
+			t.Errorf("batchSize=%d: %d changes and %d change IDs tracked", tt.batchSize, c.submittedChanges, len(c.changeIDs))
+		}
+	}
+}
+
+func TestChangeResourceRecordSetsRetriesPriorRequest(t *testing.T) {
+	priorRequest := aws.APIError{StatusCode: 400, Code: "PriorRequestNotComplete"}
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int
+		waits   []time.Duration
+		wantErr string
+	}{
+		{"succeeds", nil, 1, nil, ""},
+		{"retried", []error{priorRequest, priorRequest, nil}, 3, []time.Duration{time.Second, 2 * time.Second}, ""},
+		{"gives up", []error{priorRequest, priorRequest, priorRequest, priorRequest, priorRequest, priorRequest, priorRequest},
+			priorRequestRetries + 1, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}, "PriorRequestNotComplete"},
+		{"other error", []error{aws.APIError{StatusCode: 400, Code: "InvalidChangeBatch"}}, 1, nil, "InvalidChangeBatch"},
+		{"throttled", []error{aws.APIError{StatusCode: 400, Code: "Throttling"}, nil}, 1, nil, "Throttling"},
+	}
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			sleep = func(d time.Duration) { waits = append(waits, d) }
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			fake.changeErrs = tt.errs
+			c, _, _ := newTestCLI(t, fake)
+			rrs := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+			req := &route53.ChangeResourceRecordSetsRequest{
+				HostedZoneID: aws.String("Z1"),
+				ChangeBatch:  &route53.ChangeBatch{Changes: []route53.Change{{Action: aws.String("UPSERT"), ResourceRecordSet: &rrs}}},
+			}
+			_, err := c.changeResourceRecordSets(req)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("error %v, want none", err)
+			case tt.wantErr != "" && apiErrorCode(err) != tt.wantErr:
+				t.Errorf("error %v, want %s", err, tt.wantErr)
+			}
+			if len(fake.requests) != tt.calls {
+				t.Errorf("%d ChangeResourceRecordSets calls, want %d", len(fake.requests), tt.calls)
+			}
+			if !reflect.DeepEqual(waits, tt.waits) {
+				t.Errorf("waited %v, want %v", waits, tt.waits)
+			}
+		})
+	}
+}
+
+func TestAddDelSkipsNoOpChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(c *cli, rrs route53.ResourceRecordSet) error
+		calls int
+	}{
+		{"add an existing IP", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.addToARecordResourceRecordSet("Z1", rrs, "192.0.2.1")
+		}, 0},
+		{"delete an absent IP", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.delFromARecordResourceRecordSet("Z1", rrs, "192.0.2.9")
+		}, 0},
+		{"add a new IP", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.addToARecordResourceRecordSet("Z1", rrs, "192.0.2.1", "192.0.2.3")
+		}, 1},
+		{"delete a present IP", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.delFromARecordResourceRecordSet("Z1", rrs, "192.0.2.2")
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			fake.add("Z1", testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2"))
+			c, logs, _ := newTestCLI(t, fake)
+			rrs, err := c.getResourceRecordSet("Z1", "www.example.com.", "A", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.apply(c, rrs); err != nil {
+				t.Fatalf("error %v, want none", err)
+			}
+			if len(fake.requests) != tt.calls {
+				t.Errorf("%d ChangeResourceRecordSets calls, want %d", len(fake.requests), tt.calls)
+			}
+			if noOp := strings.Contains(logs.String(), "no change needed"); noOp != (tt.calls == 0) {
+				t.Errorf("logged %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestSplitValues(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"192.0.2.1", "192.0.2.2"}, []string{"192.0.2.1", "192.0.2.2"}},
+		{[]string{"192.0.2.1,192.0.2.2"}, []string{"192.0.2.1", "192.0.2.2"}},
+		{[]string{"192.0.2.1,192.0.2.2", "192.0.2.3"}, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{[]string{"192.0.2.1", "192.0.2.2, 192.0.2.1,", ",192.0.2.3", "192.0.2.2"}, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{[]string{",", ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := splitValues(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitValues(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestAddWithAction(t *testing.T) {
+	tests := []struct {
+		action  string
+		present bool
+		changes []string
+		values  []string
+		err     string
+	}{
+		{"create", false, []string{"CREATE www.example.com. A"}, []string{"192.0.2.2"}, ""},
+		{"create", true, nil, []string{"192.0.2.1"}, "already exists"},
+		{"update", false, nil, nil, "no ResourceRecordSets found"},
+		{"update", true, []string{"UPSERT www.example.com. A"}, []string{"192.0.2.1", "192.0.2.2"}, ""},
+		{"upsert", false, []string{"UPSERT www.example.com. A"}, []string{"192.0.2.2"}, ""},
+		{"upsert", true, []string{"UPSERT www.example.com. A"}, []string{"192.0.2.1", "192.0.2.2"}, ""},
+		// without -action the record set must exist, like update
+		{"", false, nil, nil, "no ResourceRecordSets found"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s present=%t", tt.action, tt.present), func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			if tt.present {
+				fake.add("Z1", testRecordSet("www.example.com.", "A", 300, "192.0.2.1"))
+			}
+			c, _, _ := newTestCLI(t, fake)
+			// as -cmd=add does in main
+			add := func(values []string) error {
+				rrs, err := c.getResourceRecordSet("Z1", "www.example.com", "A", "")
+				if done, err := c.createForAction("Z1", tt.action, newResourceRecordSet("www.example.com", "A", "", 300), err, values); done || err != nil {
+					return err
+				}
+				if err != nil {
+					return err
+				}
+				return c.addToARecordResourceRecordSet("Z1", rrs, values...)
+			}
+			err := add([]string{"192.0.2.2"})
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("error %v, want none", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error %v, want one containing %q", err, tt.err)
+			}
+			if got := changesOf(fake.requests); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+			rrs, err := c.getResourceRecordSet("Z1", "www.example.com", "A", "")
+			if tt.values == nil {
+				if !isNotFound(err) {
+					t.Errorf("record set %v exists, want none", recordValues(rrs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordValues(rrs); !reflect.DeepEqual(got, tt.values) {
+				t.Errorf("values %v, want %v", got, tt.values)
+			}
+		})
+	}
+}
+
+func TestCreateChangeSetIDCollision(t *testing.T) {
+	tests := []struct {
+		name   string
+		setID  string
+		force  bool
+		action string
+		err    string
+	}{
+		{"new setid", "dc2", false, "CREATE", ""},
+		{"existing setid", "dc1", false, "", "already exists, use -force"},
+		{"existing setid with -force", "dc1", true, "UPSERT", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			existing := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+			existing.SetIdentifier = aws.String("dc1")
+			existing.Weight = aws.Long(10)
+			fake.add("Z1", existing)
+			c, logs, _ := newTestCLI(t, fake)
+			c.force = tt.force
+			rrs := testRecordSet("www.example.com.", "A", 300, "192.0.2.2")
+			rrs.SetIdentifier = aws.String(tt.setID)
+			rrs.Weight = aws.Long(20)
+			change, err := c.createChange("Z1", rrs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stringValue(change.Action); got != tt.action {
+				t.Errorf("action %s, want %s", got, tt.action)
+			}
+			if warned := strings.Contains(logs.String(), "WARNING overwriting existing"); warned != tt.force {
+				t.Errorf("logged %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestMinHealthy(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(c *cli, rrs route53.ResourceRecordSet) error
+		err   string
+	}{
+		{"del leaving the minimum", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.delFromARecordResourceRecordSet("Z1", rrs, "192.0.2.3")
+		}, ""},
+		{"del going below the minimum", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.delFromARecordResourceRecordSet("Z1", rrs, "192.0.2.2", "192.0.2.3")
+		}, "fewer than -min-healthy=2"},
+		{"prune leaving the minimum", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.pruneValues("Z1", rrs, "192.0.2.1", "192.0.2.2")
+		}, ""},
+		{"prune going below the minimum", func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.pruneValues("Z1", rrs, "192.0.2.1")
+		}, "fewer than -min-healthy=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			rrs := testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+			fake.add("Z1", rrs)
+			c, _, _ := newTestCLI(t, fake)
+			c.minHealthy = 2
+			err := tt.apply(c, rrs)
+			calls := 1
+			if tt.err != "" {
+				calls = 0
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want one containing %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Errorf("error %v, want none", err)
+			}
+			if len(fake.requests) != calls {
+				t.Errorf("%d ChangeResourceRecordSets calls, want %d", len(fake.requests), calls)
+			}
+		})
+	}
+}
+
+func TestDeleteIfEmpty(t *testing.T) {
+	tests := []struct {
+		name          string
+		deleteIfEmpty bool
+		apply         func(c *cli, rrs route53.ResourceRecordSet) error
+		changes       []string
+		err           string
+	}{
+		{"del the last IP", true, func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.delFromARecordResourceRecordSet("Z1", rrs, "192.0.2.1")
+		}, []string{"DELETE www.example.com. A"}, ""},
+		{"del the last IP without the flag", false, func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.delFromARecordResourceRecordSet("Z1", rrs, "192.0.2.1")
+		}, nil, "use -delete-if-empty"},
+		{"prune every IP", true, func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.pruneValues("Z1", rrs, "192.0.2.9")
+		}, []string{"DELETE www.example.com. A"}, ""},
+		{"prune every IP without the flag", false, func(c *cli, rrs route53.ResourceRecordSet) error {
+			return c.pruneValues("Z1", rrs, "192.0.2.9")
+		}, nil, "use -delete-if-empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			rrs := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+			fake.add("Z1", rrs)
+			c, logs, _ := newTestCLI(t, fake)
+			c.deleteIfEmpty = tt.deleteIfEmpty
+			err := tt.apply(c, rrs)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("error %v, want none", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error %v, want one containing %q", err, tt.err)
+			}
+			if got := changesOf(fake.requests); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+			if tt.deleteIfEmpty {
+				if !strings.Contains(logs.String(), "deleting the record set (-delete-if-empty)") {
+					t.Errorf("auto-deletion not logged: %q", logs.String())
+				}
+				if _, err := c.getResourceRecordSet("Z1", "www.example.com.", "A", ""); !isNotFound(err) {
+					t.Errorf("record set still exists: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLowercaseType(t *testing.T) {
+	for _, recordType := range []string{"a", "aaaa", "cname", "caa", " Cname "} {
+		if !supportedType(recordType) {
+			t.Errorf("supportedType(%q) = false", recordType)
+		}
+	}
+	if got := normalizeType(" cname "); got != "CNAME" {
+		t.Errorf("normalizeType(%q) = %q, want CNAME", " cname ", got)
+	}
+
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	fake.add("Z1",
+		testRecordSet("www.example.com.", "A", 300, "192.0.2.1"),
+		testRecordSet("www.example.com.", "AAAA", 300, "2001:db8::1"),
+	)
+	c, _, _ := newTestCLI(t, fake)
+	rrs, err := c.getResourceRecordSet("Z1", "www.example.com", "aaaa", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stringValue(rrs.Type); got != "AAAA" {
+		t.Errorf("found the %s record set, want AAAA", got)
+	}
+	if err := c.addToARecordResourceRecordSet("Z1", rrs, "2001:db8::2"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"UPSERT www.example.com. AAAA"}; !reflect.DeepEqual(changesOf(fake.requests), want) {
+		t.Errorf("changes = %v, want %v", changesOf(fake.requests), want)
+	}
+	if got := newResourceRecordSet("www.example.com", "cname", "", 0); stringValue(got.Type) != "CNAME" {
+		t.Errorf("newResourceRecordSet built type %s, want CNAME", stringValue(got.Type))
+	}
+}
+
+func TestWildcardNames(t *testing.T) {
+	escapes := []struct {
+		name string
+		want string
+	}{
+		{`\052.example.com.`, "*.example.com."},
+		{`\052.dev.example.com.`, "*.dev.example.com."},
+		{"*.example.com.", "*.example.com."},
+		{`a\143b.example.com.`, "acb.example.com."},
+		// not an octal escape, kept as is
+		{`a\9xy.example.com.`, `a\9xy.example.com.`},
+	}
+	for _, tt := range escapes {
+		if got := unescapeName(tt.name); got != tt.want {
+			t.Errorf("unescapeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if !sameName(`\052.example.com.`, "*.Example.com.") {
+		t.Errorf("sameName doesn't match a wildcard with its escaped form")
+	}
+	if sameName(`\052.example.com.`, "www.example.com.") {
+		t.Errorf("sameName matches a wildcard with a name it covers")
+	}
+
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	// Route53 returns the wildcard escaped
+	fake.add("Z1",
+		testRecordSet(`\052.example.com.`, "A", 300, "192.0.2.1"),
+		testRecordSet("www.example.com.", "A", 300, "192.0.2.9"),
+	)
+	c, _, _ := newTestCLI(t, fake)
+	rrs, err := c.getResourceRecordSet("Z1", "*.example.com", "A", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.addToARecordResourceRecordSet("Z1", rrs, "192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`UPSERT \052.example.com. A`}; !reflect.DeepEqual(changesOf(fake.requests), want) {
+		t.Errorf("changes = %v, want %v", changesOf(fake.requests), want)
+	}
+	listed, err := c.listResourceRecordSets("Z1", recordSetFilter{namePrefix: "*."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || !reflect.DeepEqual(recordValues(listed[0]), []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("listed %v, want the wildcard with both values", listed)
+	}
+}
+
+func TestNormalizeNameAndRecordToZone(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalized string
+		zone       string
+		err        bool
+	}{
+		{"example.com", "example.com.", "example.com.", false},
+		{"example.com.", "example.com.", "example.com.", false},
+		{" www.example.com ", "www.example.com.", "example.com.", false},
+		{"www.example.com.", "www.example.com.", "example.com.", false},
+		{"a.b.c.example.com", "a.b.c.example.com.", "example.com.", false},
+		{"com", "com.", "", true},
+		{"com.", "com.", "", true},
+		{"", ".", "", true},
+		{"www..com", "www..com.", "", true},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.name); got != tt.normalized {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.name, got, tt.normalized)
+		}
+		zone, err := recordToZone(tt.name)
+		if (err != nil) != tt.err || zone != tt.zone {
+			t.Errorf("recordToZone(%q) = %q, %v, want %q with error %t", tt.name, zone, err, tt.zone, tt.err)
+		}
+	}
+
+	// every entry point normalizes, so a name without the trailing dot finds the record set
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	fake.add("Z1", testRecordSet("www.example.com.", "A", 300, "192.0.2.1"))
+	c, _, _ := newTestCLI(t, fake)
+	for _, name := range []string{"www.example.com", "www.example.com."} {
+		if _, err := c.getResourceRecordSet("Z1", name, "A", ""); err != nil {
+			t.Errorf("getResourceRecordSet(%q): %s", name, err)
+		}
+		if zoneID, err := c.zoneIDByName(name); err != nil || zoneID != "Z1" {
+			t.Errorf("zoneIDByName(%q) = %q, %v, want Z1", name, zoneID, err)
+		}
+		if sets, err := c.recordSetsByName("Z1", name, ""); err != nil || len(sets) != 1 {
+			t.Errorf("recordSetsByName(%q) = %v, %v, want the A record set", name, sets, err)
+		}
+	}
+}
+
+func TestApexRecord(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com.", "Z2": "example.co.uk."})
+	fake.add("Z1",
+		testRecordSet("example.com.", "SOA", 900, "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400"),
+		testRecordSet("example.com.", "NS", 172800, "ns-1.awsdns-01.org."),
+		testRecordSet("example.com.", "A", 300, "192.0.2.1"),
+		testRecordSet("www.example.com.", "A", 300, "192.0.2.9"),
+	)
+	c, _, out := newTestCLI(t, fake)
+	for name, want := range map[string]string{"example.com": "Z1", "example.co.uk.": "Z2"} {
+		if zoneID, err := c.zoneIDByName(name); err != nil || zoneID != want {
+			t.Errorf("zoneIDByName(%q) = %q, %v, want %s", name, zoneID, err, want)
+		}
+	}
+
+	get := func() route53.ResourceRecordSet {
+		t.Helper()
+		rrs, err := c.getResourceRecordSet("Z1", "example.com", "A", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rrs
+	}
+	if err := c.addToARecordResourceRecordSet("Z1", get(), "192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := recordValues(get()); !reflect.DeepEqual(got, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("apex values after add %v", got)
+	}
+	if err := c.delFromARecordResourceRecordSet("Z1", get(), "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := printRecordSets(out, outputValues, get()); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "192.0.2.2\n" {
+		t.Errorf("listed %q after the del, want 192.0.2.2", got)
+	}
+	if want := []string{"UPSERT example.com. A", "UPSERT example.com. A"}; !reflect.DeepEqual(changesOf(fake.requests), want) {
+		t.Errorf("changes = %v, want %v", changesOf(fake.requests), want)
+	}
+}
+
+func TestLookupZone(t *testing.T) {
+	tests := []struct {
+		name   string
+		zones  map[string]string
+		zoneID string
+		err    string
+	}{
+		{"a.b.c.example.com", map[string]string{"Z1": "example.com.", "Z9": "example.org."}, "Z1", ""},
+		// the last two labels name no zone, the one zone the name falls under is used
+		{"a.b.example.co.uk", map[string]string{"Z2": "example.co.uk.", "Z9": "example.org."}, "Z2", ""},
+		{"a.b.c.co.uk", map[string]string{"Z3": "b.c.co.uk.", "Z4": "c.co.uk."}, "", "is under several zones (c.co.uk., b.c.co.uk.)"},
+		{"www.example.net", map[string]string{"Z1": "example.com."}, "", "zone example.net. not found, did you mean one of example.com."},
+		{"www.example.net", map[string]string{"Z9": "other.org."}, "", "zone example.net. not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _ := newTestCLI(t, newFakeRoute53(tt.zones))
+			zoneID, err := c.zoneIDByName(tt.name)
+			switch {
+			case tt.err == "" && (err != nil || zoneID != tt.zoneID):
+				t.Errorf("zoneIDByName(%q) = %q, %v, want %s", tt.name, zoneID, err, tt.zoneID)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("zoneIDByName(%q) = %q, %v, want an error containing %q", tt.name, zoneID, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSimilarZones(t *testing.T) {
+	zones := []string{"example.com.", "dev.example.net.", "example.org.", "other.net.", "a.b.example.net."}
+	tests := []struct {
+		recordName string
+		zoneName   string
+		want       []string
+	}{
+		// zones sharing the first label of the zone looked for
+		{"www.example.net.", "example.net.", []string{"example.com.", "dev.example.net.", "example.org.", "a.b.example.net."}},
+		// zones the record falls under and zones under the wanted zone
+		{"x.a.b.example.net.", "notfound.net.", []string{"a.b.example.net."}},
+		{"www.nothing.io.", "nothing.io.", nil},
+	}
+	for _, tt := range tests {
+		if got := similarZones(tt.recordName, tt.zoneName, zones); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("similarZones(%q, %q) = %v, want %v", tt.recordName, tt.zoneName, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// apiCalls counts the AWS API requests made, for -pushgateway
+var apiCalls int64
+
+// defaultPushJob is the job label metrics are pushed with when -pushgateway-job isn't given
+const defaultPushJob = "r53tool"
+
+// pushgatewayTimeout bounds pushing the metrics at the end of a run
+const pushgatewayTimeout = 10 * time.Second
+
+// runMetrics are the metrics of one run pushed to -pushgateway
+type runMetrics struct {
+	applied  int
+	failed   int
+	apiCalls int64
+	duration time.Duration
+}
+
+// exposition writes the metrics in the Prometheus text exposition format
+func (m runMetrics) exposition() string {
+	var b strings.Builder
+	metric := func(name, kind, help string, value interface{}) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("changes_applied_total", "counter", "Changes submitted to Route53.", m.applied)
+	metric("changes_failed_total", "counter", "Changes that couldn't be applied.", m.failed)
+	metric("api_calls_total", "counter", "AWS API requests made.", m.apiCalls)
+	metric("run_duration_seconds", "gauge", "How long the run took.", m.duration.Seconds())
+	return b.String()
+}
+
+// pushMetrics sends the run's metrics to the -pushgateway, replacing the previous ones of the job.
+// The run is over by then, so failures are only logged.
+func (c *cli) pushMetrics() {
+	if c.pushgateway == "" {
+		return
+	}
+	c.mu.Lock()
+	m := runMetrics{
+		applied:  c.submittedChanges,
+		failed:   c.failedChanges,
+		apiCalls: atomic.LoadInt64(&apiCalls),
+		duration: time.Since(c.start),
+	}
+	c.mu.Unlock()
+	target := strings.TrimSuffix(c.pushgateway, "/") + "/metrics/job/" + url.PathEscape(c.pushJob)
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewBufferString(m.exposition()))
+	if err != nil {
+		c.log.Println("WARNING pushing metrics failed", err)
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Timeout: pushgatewayTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		c.log.Println("WARNING pushing metrics failed", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		c.log.Printf("WARNING pushing metrics failed, %s returned %s\n", target, resp.Status)
+	}
+}
+
+// countFailed records changes that couldn't be applied
+func (c *cli) countFailed(n int) {
+	c.mu.Lock()
+	c.failedChanges += n
+	c.mu.Unlock()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExposition(t *testing.T) {
+	m := runMetrics{applied: 12, failed: 3, apiCalls: 40, duration: 1500 * time.Millisecond}
+	want := `# HELP changes_applied_total Changes submitted to Route53.
+# TYPE changes_applied_total counter
+changes_applied_total 12
+# HELP changes_failed_total Changes that couldn't be applied.
+# TYPE changes_failed_total counter
+changes_failed_total 3
+# HELP api_calls_total AWS API requests made.
+# TYPE api_calls_total counter
+api_calls_total 40
+# HELP run_duration_seconds How long the run took.
+# TYPE run_duration_seconds gauge
+run_duration_seconds 1.5
+`
+	if got := m.exposition(); got != want {
+		t.Errorf("exposition() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, contentType = r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	c, logs, _ := newTestCLI(t, newFakeRoute53(nil))
+	c.pushgateway = server.URL + "/"
+	c.pushJob = "dns deploy"
+	c.start = time.Now()
+	c.submittedChanges = 7
+	c.countFailed(2)
+	c.pushMetrics()
+
+	if method != http.MethodPut || path != "/metrics/job/dns%20deploy" {
+		t.Errorf("pushed with %s %s, want PUT /metrics/job/dns%%20deploy", method, path)
+	}
+	if contentType != "text/plain; version=0.0.4" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	for _, line := range []string{"\nchanges_applied_total 7\n", "\nchanges_failed_total 2\n"} {
+		if !strings.Contains(body, line) {
+			t.Errorf("pushed\n%s\nwithout %q", body, strings.TrimSpace(line))
+		}
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %q for a successful push", logs.String())
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/sts"
+)
+
+const (
+	mfaSessionsFile = "mfa_sessions.json"
+	// mfaSessionDuration is how long GetSessionToken credentials last, AssumeRole sessions are capped at an hour
+	mfaSessionDuration = 12 * time.Hour
+	mfaRoleDuration    = time.Hour
+	// sessions about to expire are renewed rather than risk them expiring mid-run
+	mfaExpiryWindow = 5 * time.Minute
+)
+
+// mfaProfile holds the MFA settings of a profile in ~/.aws/config, serial is empty when it doesn't need MFA
+type mfaProfile struct {
+	name          string
+	serial        string
+	roleARN       string
+	sourceProfile string
+}
+
+// loadMFAProfile reads the mfa_serial, role_arn and source_profile settings of a profile, a missing config file means no MFA
+func loadMFAProfile(path, profileName string) (mfaProfile, error) {
+	p := mfaProfile{name: profileName}
+	if profileName == "" {
+		return p, nil
+	}
+	settings, err := profileSettings(path, profileName)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	p.serial = settings["mfa_serial"]
+	p.roleARN = settings["role_arn"]
+	p.sourceProfile = settings["source_profile"]
+	return p, nil
+}
+
+// key identifies the cached session of the profile
+func (p mfaProfile) key() string {
+	return strings.Join([]string{p.name, p.serial, p.roleARN}, " ")
+}
+
+// mfaSession is temporary credentials obtained with an MFA code
+type mfaSession struct {
+	credsFile
+	Expiration time.Time `json:"Expiration"`
+}
+
+// valid reports whether the session can still be used for a whole run
+func (s mfaSession) valid() bool {
+	return s.AccessKeyID != "" && time.Now().Add(mfaExpiryWindow).Before(s.Expiration)
+}
+
+// mfaSessions caches MFA sessions per profile, so the code is only asked for again once the session expires
+type mfaSessions struct {
+	path     string
+	Sessions map[string]mfaSession `json:"sessions"`
+}
+
+// loadMFASessions reads the cached sessions, a missing file is treated as empty
+func loadMFASessions() (*mfaSessions, error) {
+	s := &mfaSessions{
+		path:     filepath.Join(stateDir(), mfaSessionsFile),
+		Sessions: make(map[string]mfaSession),
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Sessions == nil {
+		s.Sessions = make(map[string]mfaSession)
+	}
+	return s, nil
+}
+
+// save writes the cached sessions, dropping expired ones
+func (s *mfaSessions) save() error {
+	for key, session := range s.Sessions {
+		if !session.valid() {
+			delete(s.Sessions, key)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), stateDirPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, data, stateFilePermissions)
+}
+
+// stsAPI is the part of the STS client mfaCreds uses, tests replace it
+type stsAPI interface {
+	AssumeRole(*sts.AssumeRoleRequest) (*sts.AssumeRoleResponse, error)
+	GetSessionToken(*sts.GetSessionTokenRequest) (*sts.GetSessionTokenResponse, error)
+}
+
+// mfaCreds returns temporary credentials for a profile with an mfa_serial, see mfaSessionCreds.
+// STS is called with the keys of the profile's source_profile when it has a role_arn, its own keys otherwise.
+func (c *cli) mfaCreds(p mfaProfile, tokenCode string, client *http.Client) (aws.CredentialsProvider, error) {
+	return c.mfaSessionCreds(p, tokenCode, func() (stsAPI, error) {
+		source := p.name
+		if p.roleARN != "" && p.sourceProfile != "" {
+			source = p.sourceProfile
+		}
+		base, err := aws.ProfileCreds("", source, 10*time.Minute)
+		if err == nil {
+			_, err = base.Credentials()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %s", source, err)
+		}
+		return sts.New(base, route53SigningRegion, client), nil
+	})
+}
+
+// mfaSessionCreds returns the cached session of the profile while it lasts. Otherwise the MFA code,
+// tokenCode or prompted for, is exchanged with the STS client newSTS returns, AssumeRole when the profile
+// has a role_arn and GetSessionToken otherwise, and the new session cached.
+func (c *cli) mfaSessionCreds(p mfaProfile, tokenCode string, newSTS func() (stsAPI, error)) (aws.CredentialsProvider, error) {
+	cache, err := loadMFASessions()
+	if err != nil {
+		return nil, fmt.Errorf("reading cached MFA sessions %w", err)
+	}
+	if session, exists := cache.Sessions[p.key()]; exists && session.valid() {
+		return aws.Creds(session.AccessKeyID, session.SecretAccessKey, session.SessionToken), nil
+	}
+
+	svc, err := newSTS()
+	if err != nil {
+		return nil, err
+	}
+	if tokenCode == "" {
+		if tokenCode, err = promptMFACode(p); err != nil {
+			return nil, err
+		}
+	}
+
+	var creds *sts.Credentials
+	if p.roleARN != "" {
+		resp, err := svc.AssumeRole(&sts.AssumeRoleRequest{
+			RoleARN:         aws.String(p.roleARN),
+			RoleSessionName: aws.String(fmt.Sprintf("r53tool-%d", time.Now().Unix())),
+			SerialNumber:    aws.String(p.serial),
+			TokenCode:       aws.String(tokenCode),
+			DurationSeconds: aws.Integer(int(mfaRoleDuration.Seconds())),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("assuming %s with MFA %w", p.roleARN, err)
+		}
+		creds = resp.Credentials
+	} else {
+		resp, err := svc.GetSessionToken(&sts.GetSessionTokenRequest{
+			SerialNumber:    aws.String(p.serial),
+			TokenCode:       aws.String(tokenCode),
+			DurationSeconds: aws.Integer(int(mfaSessionDuration.Seconds())),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getting an MFA session token %w", err)
+		}
+		creds = resp.Credentials
+	}
+	if creds == nil {
+		return nil, fmt.Errorf("STS returned no credentials for profile %s", p.name)
+	}
+
+	session := mfaSession{
+		credsFile: credsFile{
+			AccessKeyID:     stringValue(creds.AccessKeyID),
+			SecretAccessKey: stringValue(creds.SecretAccessKey),
+			SessionToken:    stringValue(creds.SessionToken),
+		},
+		Expiration: creds.Expiration,
+	}
+	cache.Sessions[p.key()] = session
+	if err := cache.save(); err != nil {
+		c.log.Println("WARNING could not cache the MFA session", err)
+	}
+	return aws.Creds(session.AccessKeyID, session.SecretAccessKey, session.SessionToken), nil
+}
+
+// promptMFACode asks for the current code of the profile's MFA device on the terminal
+func promptMFACode(p mfaProfile) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("profile %s needs an MFA code, use -mfa-token when not running interactively", p.name)
+	}
+	fmt.Fprintf(os.Stderr, "MFA code for %s: ", p.serial)
+	code, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	code = strings.TrimSpace(code)
+	if !validMFACode(code) {
+		return "", fmt.Errorf("MFA codes are 6 digits")
+	}
+	return code, nil
+}
+
+// validMFACode reports whether code looks like the 6 digits an MFA device shows
+func validMFACode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
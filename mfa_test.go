@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/sts"
+)
+
+// fakeSTS hands out numbered session credentials lasting expiresIn
+type fakeSTS struct {
+	expiresIn    time.Duration
+	assumeRoles  []*sts.AssumeRoleRequest
+	sessionCalls []*sts.GetSessionTokenRequest
+}
+
+func (f *fakeSTS) credentials() *sts.Credentials {
+	n := len(f.assumeRoles) + len(f.sessionCalls)
+	return &sts.Credentials{
+		AccessKeyID:     aws.String(fmt.Sprintf("ASIA%d", n)),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+		Expiration:      time.Now().Add(f.expiresIn),
+	}
+}
+
+func (f *fakeSTS) AssumeRole(req *sts.AssumeRoleRequest) (*sts.AssumeRoleResponse, error) {
+	resp := &sts.AssumeRoleResponse{Credentials: f.credentials()}
+	f.assumeRoles = append(f.assumeRoles, req)
+	return resp, nil
+}
+
+func (f *fakeSTS) GetSessionToken(req *sts.GetSessionTokenRequest) (*sts.GetSessionTokenResponse, error) {
+	resp := &sts.GetSessionTokenResponse{Credentials: f.credentials()}
+	f.sessionCalls = append(f.sessionCalls, req)
+	return resp, nil
+}
+
+func TestMFASessionCredsCachesSession(t *testing.T) {
+	for _, p := range []mfaProfile{
+		{name: "prod", serial: "arn:aws:iam::123456789012:mfa/ops"},
+		{name: "prod-admin", serial: "arn:aws:iam::123456789012:mfa/ops", roleARN: "arn:aws:iam::123456789012:role/admin", sourceProfile: "prod"},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			c, _, _ := newTestCLI(t, nil)
+			fake := &fakeSTS{expiresIn: time.Hour}
+			newSTS := func() (stsAPI, error) { return fake, nil }
+			accessKey := func() string {
+				t.Helper()
+				creds, err := c.mfaSessionCreds(p, "123456", newSTS)
+				if err != nil {
+					t.Fatal(err)
+				}
+				keys, err := creds.Credentials()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return keys.AccessKeyID
+			}
+			calls := func() int { return len(fake.assumeRoles) + len(fake.sessionCalls) }
+
+			if key := accessKey(); key != "ASIA0" || calls() != 1 {
+				t.Fatalf("first run got %s with %d STS calls, want ASIA0 with 1", key, calls())
+			}
+			if p.roleARN != "" && (len(fake.assumeRoles) != 1 || stringValue(fake.assumeRoles[0].SerialNumber) != p.serial) {
+				t.Errorf("a role_arn profile made AssumeRole calls %v", fake.assumeRoles)
+			}
+			if key := accessKey(); key != "ASIA0" || calls() != 1 {
+				t.Errorf("second run got %s with %d STS calls, want the cached ASIA0", key, calls())
+			}
+
+			// age the cached session until it's about to expire, it must be renewed rather than used
+			sessions, err := loadMFASessions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := sessions.Sessions[p.key()]
+			session.Expiration = time.Now().Add(mfaExpiryWindow / 2)
+			sessions.Sessions[p.key()] = session
+			data, err := json.Marshal(sessions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(sessions.path, data, stateFilePermissions); err != nil {
+				t.Fatal(err)
+			}
+			if key := accessKey(); key != "ASIA1" || calls() != 2 {
+				t.Errorf("run after expiry got %s with %d STS calls, want a new ASIA1", key, calls())
+			}
+		})
+	}
+}
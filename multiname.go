@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// nameList is the -name flag, which may be repeated or hold comma separated names
+type nameList []string
+
+func (l *nameList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *nameList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+// changeNames applies add, del or swap to the record set of each name independently,
+// submitting the changes of each hosted zone in a single ChangeResourceRecordSets.
+// It stops at the first error, the result counts what was done until then.
+func (c *cli) changeNames(action string, names []string, zoneID, recordType, setID string, ips []string, swaps []swapPair) (batchResult, error) {
+	var result batchResult
+	fail := func(err error) (batchResult, error) {
+		result.fail(err)
+		return result, err
+	}
+	var zones []*zoneChanges
+	byZone := make(map[string]*zoneChanges)
+	for _, name := range names {
+		name = normalizeName(name)
+		nameZoneID := zoneID
+		if nameZoneID == "" {
+			var err error
+			if nameZoneID, err = c.zoneIDByName(name); err != nil {
+				return fail(fmt.Errorf("%s: %w", name, zoneLookupError(err)))
+			}
+		}
+		rrs, err := c.getResourceRecordSet(nameZoneID, name, recordType, setID)
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", name, err))
+		}
+		var change *route53.Change
+		switch action {
+		case "add":
+			change, err = c.upsertChange(rrs, c.withValuesAdded(rrs, ips...))
+		case "del":
+			change, err = c.upsertChange(rrs, c.withValuesRemoved(rrs, ips...))
+			c.backup(rrs)
+		case "swap":
+			change, err = c.swapChange(rrs, swaps)
+			c.backup(rrs)
+		default:
+			return fail(fmt.Errorf("action not implemented for several names %s", action))
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", name, err))
+		}
+		if change == nil {
+			c.log.Printf("%s: no change needed\n", name)
+			result.skipped++
+			continue
+		}
+		zc, exists := byZone[nameZoneID]
+		if !exists {
+			zc = &zoneChanges{zoneID: nameZoneID}
+			byZone[nameZoneID] = zc
+			zones = append(zones, zc)
+		}
+		zc.changes = append(zc.changes, *change)
+	}
+
+	for _, zc := range zones {
+		submitted, err := c.submitBatches(zc.zoneID, zc.changes)
+		result.applied += submitted
+		result.zones++
+		if err != nil {
+			return fail(fmt.Errorf("zoneID=%s: %w", zc.zoneID, err))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangeNamesBatchesOneZone(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	fake.add("Z1",
+		testRecordSet("www.example.com.", "A", 300, "192.0.2.1"),
+		testRecordSet("api.example.com.", "A", 300, "192.0.2.2"),
+	)
+	c, _, _ := newTestCLI(t, fake)
+	var names nameList
+	if err := names.Set("www.example.com, api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.changeNames("add", names, "", "A", "", []string{"192.0.2.3"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.requests) != 1 {
+		t.Fatalf("%d ChangeResourceRecordSets calls, want the two names in one", len(fake.requests))
+	}
+	if want := []string{"UPSERT www.example.com. A", "UPSERT api.example.com. A"}; !reflect.DeepEqual(changesOf(fake.requests), want) {
+		t.Errorf("changes = %v, want %v", changesOf(fake.requests), want)
+	}
+	if result.applied != 2 || result.zones != 1 {
+		t.Errorf("%d applied across %d zones, want 2 across 1", result.applied, result.zones)
+	}
+	for _, name := range names {
+		rrs, err := c.getResourceRecordSet("Z1", name, "A", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values := recordValues(rrs); len(values) != 2 || values[1] != "192.0.2.3" {
+			t.Errorf("%s has values %v after the add", name, values)
+		}
+	}
+}
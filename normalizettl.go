@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// normalizeTTL sets the TTL of every record set of the zone matching filter to ttl, submitting the changes in batches.
+// Alias record sets have no TTL of their own and the apex SOA and NS record sets are managed by Route53, both are skipped.
+func (c *cli) normalizeTTL(zone zoneRef, filter recordSetFilter, ttl int64) error {
+	sets, err := c.listResourceRecordSets(zone.id, filter)
+	if err != nil {
+		return fmt.Errorf("listing resource record sets %w", err)
+	}
+	if zone.name, err = c.apexName(zone, sets); err != nil {
+		return err
+	}
+	var changes []route53.Change
+	for _, rrs := range sets {
+		if rrs.AliasTarget != nil || stringValue(rrs.Type) == "SOA" || isApexManaged(rrs, zone.name) || (rrs.TTL != nil && *rrs.TTL == ttl) {
+			continue
+		}
+		updated := copyResourceRecordSet(rrs)
+		updated.TTL = aws.Long(ttl)
+		if err := c.validateRecordSet(updated); err != nil {
+			return err
+		}
+		if c.verbose {
+			c.log.Printf("%s %s setIdentifier=%s ttl %s -> %d\n", stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier), longValue(rrs.TTL), ttl)
+		}
+		prior := rrs
+		c.notePrior(&updated, &prior)
+		changes = append(changes, route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &updated})
+		c.backup(rrs)
+	}
+	if len(changes) == 0 {
+		c.log.Printf("all %d record set(s) in %s already have ttl=%d\n", len(sets), zone.name, ttl)
+		return nil
+	}
+	if err := c.submitChanges(zone.id, changes); err != nil {
+		return err
+	}
+	verb := "changed"
+	if c.dryRun {
+		verb = "would change"
+	}
+	c.log.Printf("%s the ttl of %d of %d record set(s) in %s to %d\n", verb, len(changes), len(sets), zone.name, ttl)
+	return nil
+}
+
+// apexName is the name of the zone, which is unknown when it was given by -zoneid.
+// It's then the name of the zone's SOA record set, or looked up when the listing has none.
+func (c *cli) apexName(zone zoneRef, sets []route53.ResourceRecordSet) (string, error) {
+	if zone.name != "" {
+		return zone.name, nil
+	}
+	for _, rrs := range sets {
+		if stringValue(rrs.Type) == "SOA" {
+			return stringValue(rrs.Name), nil
+		}
+	}
+	if err := c.checkDeadline(); err != nil {
+		return "", err
+	}
+	resp, err := c.r53.GetHostedZone(&route53.GetHostedZoneRequest{ID: aws.String(zone.id)})
+	if err != nil {
+		return "", fmt.Errorf("getting the name of zoneID=%s %w", zone.id, err)
+	}
+	if resp.HostedZone == nil {
+		return "", fmt.Errorf("zoneID=%s not found", zone.id)
+	}
+	return stringValue(resp.HostedZone.Name), nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTTLByZoneIDSkipsApex(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter recordSetFilter
+	}{
+		{"all record sets", recordSetFilter{}},
+		// without the SOA in the listing the zone name has to be looked up
+		{"only NS", recordSetFilter{recordType: "NS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			fake.add("Z1",
+				testRecordSet("example.com.", "SOA", 900, "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400"),
+				testRecordSet("example.com.", "NS", 172800, "ns-1.awsdns-01.org."),
+				testRecordSet("sub.example.com.", "NS", 172800, "ns1.sub.example.net."),
+				testRecordSet("www.example.com.", "A", 300, "192.0.2.1"),
+			)
+			c, _, _ := newTestCLI(t, fake)
+			if err := c.normalizeTTL(zoneRef{id: "Z1"}, tt.filter, 60); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"UPSERT sub.example.com. NS"}
+			if tt.filter.recordType == "" {
+				want = append(want, "UPSERT www.example.com. A")
+			}
+			if got := changesOf(fake.requests); !reflect.DeepEqual(got, want) {
+				t.Errorf("changes = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// options holds the parsed command line flags that are checked before any AWS call
+type options struct {
+	action       string
+	names        []string
+	recordType   string
+	setID        string
+	values       []string
+	args         []string
+	setFlags     map[string]bool
+	batchFile    string
+	zoneID       string
+	zoneIDFile   string
+	output       string
+	color        string
+	allZones     bool
+	allSetIDs    bool
+	verify       bool
+	explain      bool
+	interval     time.Duration
+	dryRun       bool
+	changeAction string
+	onlyType     string
+	aliasTarget  string
+	credsFile    string
+	profile      string
+	mfaToken     string
+	multiValue   bool
+	ttl          int64
+	ttlMin       int64
+	ttlMax       int64
+	weight       int64
+	idempotent   bool
+	dedupWindow  time.Duration
+	token        string
+	preflight    string
+	minHealthy   int
+	maxValues    int
+	inputFormat  string
+	nameRegex    string
+	valueRegex   string
+	parallel     int
+	batchSize    int
+	deleteEmpty  bool
+	undoCount    int
+	strictSetID  bool
+	zoneFilter   string
+	autoConfirm  string
+	auditLog     string
+	since        time.Duration
+}
+
+// validationErrors collects every problem found by options.validate
+type validationErrors []error
+
+func (e validationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\nERROR: ")
+}
+
+// validate checks the flag values and their combinations, reporting all the problems found rather than the first
+func (o options) validate() error {
+	var errs validationErrors
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	name := ""
+	if len(o.names) > 0 {
+		name = o.names[0]
+	}
+	recordType := normalizeType(o.recordType)
+
+	switch o.action {
+	case "swap":
+		pairs, err := parseSwapPairs(o.values)
+		switch {
+		case err != nil:
+			fail("%s", err)
+		case len(pairs) == 0:
+			fail("swap needs one or more old=new ipaddr pairs")
+		}
+	case "rebalance":
+		weights, err := parseSetWeights(o.values)
+		switch {
+		case err != nil:
+			fail("%s", err)
+		case len(weights) == 0:
+			fail("rebalance needs one or more setid=weight pairs")
+		}
+		if o.setID != "" {
+			fail("rebalance takes the set identifiers as setid=weight arguments, not -setid")
+		}
+	case "cutover":
+		targets, err := parseCutover(o.args, recordType)
+		switch {
+		case err != nil:
+			fail("%s", err)
+		case len(targets) == 0:
+			fail("cutover needs one or more name=ipaddr1,ipaddr2 mappings")
+		}
+		if len(o.names) > 0 {
+			fail("cutover takes the names as name=ipaddr arguments, not -name")
+		}
+	case "":
+		if o.batchFile == "" {
+			fail("supported commands are %s", commands)
+		}
+	case "find-ip":
+		if len(o.values) == 0 {
+			fail("find-ip needs one or more ipaddrs")
+		}
+		if !o.allZones && name == "" && o.zoneID == "" && o.zoneIDFile == "" {
+			fail("find-ip needs the zone -name or -zoneid to search, or -all-zones")
+		}
+	case "add", "del", "prune":
+		if len(o.values) == 0 {
+			fail("%s needs one or more ipaddrs", o.action)
+		}
+		if o.action != "del" && supportedType(recordType) {
+			for _, v := range o.values {
+				if err := validateValue(recordType, v); err != nil {
+					fail("%s", err)
+				}
+			}
+		}
+	case "import-zone":
+		if o.batchFile == "" || name == "" {
+			fail("import-zone needs the zone -name and the zone -file to import")
+		}
+		if len(o.values) != 0 {
+			fail("import-zone does not take any ipaddrs")
+		}
+	case "diff":
+		if o.batchFile == "" {
+			fail("diff needs -file with the desired record sets")
+		}
+	case "validate":
+		if o.batchFile == "" {
+			fail("validate needs the -file to check")
+		}
+		if len(o.values) != 0 {
+			fail("validate does not take any ipaddrs")
+		}
+	case "alias":
+		if len(o.values) != 0 {
+			fail("alias does not take any ipaddrs")
+		}
+		if o.aliasTarget == "" {
+			fail("alias needs the -alias-target DNS name")
+		}
+	case "update":
+		if len(o.values) != 0 {
+			fail("update does not take any ipaddrs")
+		}
+		if !o.setFlags["evaluate-target-health"] && o.ttl == 0 {
+			fail("update needs a setting to change, -evaluate-target-health or -ttl")
+		}
+	case "convert":
+		if len(o.values) != 0 {
+			fail("convert does not take any ipaddrs")
+		}
+		if o.setID == "" || !o.setFlags["weight"] {
+			fail("convert needs the -setid and -weight of the new weighted record set")
+		}
+	case "simplify":
+		if len(o.values) != 0 {
+			fail("simplify does not take any ipaddrs")
+		}
+		if o.setID == "" {
+			fail("simplify needs the -setid of the record set to make simple")
+		}
+	case "watch":
+		if len(o.values) != 0 {
+			fail("watch does not take any ipaddrs")
+		}
+		if o.interval <= 0 {
+			fail("-interval must be positive")
+		}
+	case "normalize-ttl":
+		if len(o.values) != 0 {
+			fail("normalize-ttl does not take any ipaddrs")
+		}
+		if o.ttl <= 0 {
+			fail("normalize-ttl needs the -ttl to set")
+		}
+	case "recent":
+		if len(o.values) != 0 {
+			fail("recent does not take any ipaddrs")
+		}
+		if o.auditLog == "" {
+			fail("recent needs -audit-log, changes are only known when they were logged to it")
+		}
+		if o.since <= 0 {
+			fail("-since must be positive")
+		}
+	case "undo":
+		if len(o.values) != 0 {
+			fail("undo does not take any ipaddrs")
+		}
+		if o.undoCount < 1 {
+			fail("-count must be at least 1")
+		}
+	case "list", "list-all", "list-zones", "list-global", "from-dns":
+		if len(o.values) != 0 {
+			fail("%s does not take any ipaddrs", o.action)
+		}
+	default:
+		fail("supported commands are %s", commands)
+	}
+
+	if !supportedType(recordType) {
+		fail("only operations on A, AAAA, CNAME and CAA records are currently supported")
+	}
+	if recordType == "CAA" && (o.verify || o.preflight != "" || o.action == "from-dns") {
+		fail("-verify, -preflight and from-dns don't work with CAA records")
+	}
+
+	if o.zoneIDFile != "" && o.zoneID != "" {
+		fail("-zoneid and -zoneid-file can't be used together")
+	}
+
+	if !validOutput(o.output) {
+		fail("supported output formats are xml|table|values|yaml|json|zonefile|hash|awscli")
+	}
+	switch o.color {
+	case colorAuto, colorAlways, colorNever:
+	default:
+		fail("-color must be auto, always or never")
+	}
+	if o.output == outputAWSCLI && !o.dryRun && o.action != "diff" {
+		fail("-output=awscli only works with -dry-run and -cmd=diff")
+	}
+	if o.action == "list-zones" && (o.output == outputZonefile || o.output == outputHash) {
+		fail("-output=%s describes record sets, list-zones supports xml|table|values|yaml|json", o.output)
+	}
+
+	if len(o.names) > 1 {
+		if o.action != "add" && o.action != "del" && o.action != "swap" {
+			fail("several -name values only work with -cmd=add, del and swap")
+		}
+		if o.allSetIDs || o.verify || o.explain || o.changeAction == "create" || o.changeAction == "upsert" {
+			fail("-all-setids, -verify, -explain and -action=create|upsert work with a single -name")
+		}
+	}
+
+	switch o.changeAction {
+	case "", "update":
+	case "create", "upsert":
+		if o.action != "add" {
+			fail("-action=%s only works with -cmd=add", o.changeAction)
+		}
+	default:
+		fail("-action must be create, update or upsert")
+	}
+
+	if o.onlyType != "" && o.action != "list-all" && o.action != "list-global" && o.action != "find-ip" && o.action != "normalize-ttl" {
+		fail("-only-type only works with -cmd=list-all, list-global, find-ip and normalize-ttl, use -type for single record sets")
+	}
+
+	if o.verify && o.action != "add" && o.action != "swap" {
+		fail("-verify only works with -cmd=add and -cmd=swap")
+	}
+
+	if o.deleteEmpty && o.action != "del" && o.action != "prune" && o.batchFile == "" {
+		fail("-delete-if-empty only works with -cmd=del, -cmd=prune and -file batches")
+	}
+
+	if o.setFlags["count"] && o.action != "undo" {
+		fail("-count only works with -cmd=undo")
+	}
+
+	if o.setFlags["since"] && o.action != "recent" {
+		fail("-since only works with -cmd=recent")
+	}
+
+	if o.allSetIDs && (o.action != "del" || o.setID != "") {
+		fail("-all-setids only works with -cmd=del and without -setid")
+	}
+
+	if o.credsFile != "" && o.profile != "" {
+		fail("-creds-file and -profile can't be used together")
+	}
+
+	if o.mfaToken != "" && (o.profile == "" || !validMFACode(o.mfaToken)) {
+		fail("-mfa-token needs -profile and must be the 6 digit MFA code")
+	}
+
+	if err := validateSetID(o.setID); err != nil {
+		fail("-setid %s", err)
+	}
+
+	if o.multiValue && o.setID == "" {
+		fail("-multivalue requires -setid")
+	}
+
+	if o.strictSetID && o.setFlags["weight"] && o.setID == "" {
+		fail("-weight makes a weighted record set, which needs a -setid (-strict-setid=false skips this check)")
+	}
+
+	if o.ttl < 0 {
+		fail("-ttl must not be negative")
+	}
+
+	if o.ttlMin < 0 || o.ttlMax < 0 || (o.ttlMax > 0 && o.ttlMin > o.ttlMax) {
+		fail("-ttl-min and -ttl-max must not be negative, and -ttl-min must not be above -ttl-max")
+	}
+
+	if o.weight < 0 || o.weight > maxWeight {
+		fail("-weight must be between 0 and %d", maxWeight)
+	}
+
+	if o.idempotent && o.dedupWindow <= 0 {
+		fail("-idempotent-window must be positive")
+	}
+
+	if o.token != "" {
+		switch o.action {
+		case "list", "list-all", "list-zones", "list-global", "find-ip", "diff", "watch", "recent", "validate":
+			fail("-idempotency-token only works with commands changing record sets")
+		}
+		if len(o.token) > maxTokenLength {
+			fail("-idempotency-token must be at most %d characters", maxTokenLength)
+		}
+	}
+
+	switch o.preflight {
+	case "", "tcp", "http":
+	default:
+		fail("-preflight must be tcp or http")
+	}
+
+	if o.minHealthy < 0 {
+		fail("-min-healthy must not be negative")
+	}
+
+	if o.maxValues < 0 {
+		fail("-max-values must not be negative")
+	}
+
+	switch strings.ToLower(o.inputFormat) {
+	case "", "json", "yaml", "csv":
+	default:
+		fail("-input-format must be json, yaml or csv")
+	}
+
+	if _, err := regexp.Compile(o.nameRegex); err != nil {
+		fail("-name-regex %s", err)
+	}
+	if _, err := regexp.Compile(o.valueRegex); err != nil {
+		fail("-value-regex %s", err)
+	}
+
+	if o.autoConfirm != "" {
+		if _, _, err := parseTag(o.autoConfirm); err != nil {
+			fail("-auto-confirm-tag %s", err)
+		}
+	}
+
+	if o.zoneFilter != "" && (o.batchFile == "" || o.action == "import-zone") {
+		fail("-zone-filter only works with -file batches")
+	}
+
+	if o.parallel < 1 {
+		fail("-parallel-zones must be at least 1")
+	}
+
+	if o.batchSize < 1 || o.batchSize > maxBatchSize {
+		fail("-batch-size must be between 1 and %d", maxBatchSize)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
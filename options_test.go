@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// validOptions are the options of r53tool -name=www.example.com 192.0.2.1 with the flag defaults
+func validOptions() options {
+	return options{
+		action:      "add",
+		names:       []string{"www.example.com"},
+		recordType:  "A",
+		values:      []string{"192.0.2.1"},
+		args:        []string{"192.0.2.1"},
+		setFlags:    map[string]bool{},
+		output:      "xml",
+		color:       "auto",
+		parallel:    1,
+		batchSize:   defaultBatchSize,
+		strictSetID: true,
+	}
+}
+
+func TestOptionsValidateReportsEveryProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *options)
+		want   []string
+	}{
+		{"valid", func(o *options) {}, nil},
+		{
+			"one problem",
+			func(o *options) { o.ttl = -1 },
+			[]string{"-ttl must not be negative"},
+		},
+		{
+			"several flags",
+			func(o *options) {
+				o.ttl = -1
+				o.color = "sometimes"
+				o.batchSize = maxBatchSize + 1
+			},
+			[]string{
+				"-color must be auto, always or never",
+				"-ttl must not be negative",
+				"-batch-size must be between 1 and 1000",
+			},
+		},
+		{
+			"command and flags",
+			func(o *options) {
+				o.values = nil
+				o.multiValue = true
+				o.parallel = 0
+			},
+			[]string{
+				"add needs one or more ipaddrs",
+				"-multivalue requires -setid",
+				"-parallel-zones must be at least 1",
+			},
+		},
+		{
+			"invalid values",
+			func(o *options) {
+				o.values = []string{"192.0.2.300", "2001:db8::1"}
+				o.weight = 300
+				o.setID = "dc1"
+			},
+			[]string{
+				`"192.0.2.300" is not an IPv4 address`,
+				`"2001:db8::1" is not an IPv4 address`,
+				"-weight must be between 0 and 255",
+			},
+		},
+		{
+			"list-zones output",
+			func(o *options) {
+				o.action = "list-zones"
+				o.values = nil
+				o.output = outputHash
+				o.color = "rainbow"
+			},
+			[]string{
+				"-color must be auto, always or never",
+				"-output=hash describes record sets, list-zones supports xml|table|values|yaml|json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOptions()
+			tt.modify(&o)
+			err := o.validate()
+			var got []string
+			var errs validationErrors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					got = append(got, e.Error())
+				}
+			} else if err != nil {
+				t.Fatalf("validate() = %T %v, want validationErrors", err, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() reported %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
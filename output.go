@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	outputXML    = "xml"
+	outputTable  = "table"
+	outputValues = "values"
+	outputYAML   = "yaml"
+	outputJSON   = "json"
+	// outputZonefile writes record sets as BIND master file lines, which import-zone reads back
+	outputZonefile = "zonefile"
+)
+
+const (
+	ansiBold  = "\x1b[1m"
+	ansiReset = "\x1b[0m"
+)
+
+// validOutput reports whether format is a supported -output value
+func validOutput(format string) bool {
+	switch format {
+	case outputXML, outputTable, outputValues, outputYAML, outputJSON, outputZonefile, outputHash, outputAWSCLI:
+		return true
+	}
+	return false
+}
+
+// -color values
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// colorMode is the -color setting, auto colors only terminals
+var colorMode = colorAuto
+
+// colorEnabled decides whether to emit ANSI colors, by default only when w is a terminal.
+// NO_COLOR turns colors off even with -color=always.
+func colorEnabled(w io.Writer) bool {
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+	switch colorMode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// stringValue dereferences an optional string, returning "" for nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// longValue formats an optional number, returning "" for nil
+func longValue(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+// recordValues returns the values of a record set, or the alias target for alias records
+func recordValues(rrs route53.ResourceRecordSet) []string {
+	if rrs.AliasTarget != nil {
+		return []string{"ALIAS " + stringValue(rrs.AliasTarget.DNSName)}
+	}
+	var values []string
+	for _, rr := range rrs.ResourceRecords {
+		values = append(values, stringValue(rr.Value))
+	}
+	return values
+}
+
+// sortRecordSets returns copies of the record sets with their values sorted,
+// ordered by name, type and set identifier, so listings can be diffed over time
+func sortRecordSets(sets []route53.ResourceRecordSet) []route53.ResourceRecordSet {
+	sorted := make([]route53.ResourceRecordSet, len(sets))
+	for i, rrs := range sets {
+		rrs = copyResourceRecordSet(rrs)
+		sort.Slice(rrs.ResourceRecords, func(a, b int) bool {
+			return stringValue(rrs.ResourceRecords[a].Value) < stringValue(rrs.ResourceRecords[b].Value)
+		})
+		sorted[i] = rrs
+	}
+	sort.SliceStable(sorted, func(a, b int) bool {
+		x, y := sorted[a], sorted[b]
+		if nx, ny := unescapeName(stringValue(x.Name)), unescapeName(stringValue(y.Name)); nx != ny {
+			return nx < ny
+		}
+		if stringValue(x.Type) != stringValue(y.Type) {
+			return stringValue(x.Type) < stringValue(y.Type)
+		}
+		return stringValue(x.SetIdentifier) < stringValue(y.SetIdentifier)
+	})
+	return sorted
+}
+
+// printRecordSets writes the record sets to w in the requested output format
+func printRecordSets(w io.Writer, format string, sets ...route53.ResourceRecordSet) error {
+	switch format {
+	case outputTable:
+		return printTable(w, colorEnabled(w), sets)
+	case outputValues:
+		return printValues(w, sets)
+	case outputYAML:
+		return printYAML(w, sets)
+	case outputJSON:
+		return printJSON(w, sets)
+	case outputZonefile:
+		return printZoneFile(w, sets)
+	case outputHash:
+		return printHashes(w, sets)
+	default:
+		for _, rrs := range sets {
+			if err := printResourceRecordSet(w, rrs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// printTable writes the record sets as an aligned table, with a bold header when color is true
+func printTable(w io.Writer, color bool, sets []route53.ResourceRecordSet) error {
+	rows := [][]string{{"NAME", "TYPE", "TTL", "SETID", "WEIGHT", "VALUES"}}
+	for _, rrs := range sets {
+		rows = append(rows, []string{
+			stringValue(rrs.Name),
+			stringValue(rrs.Type),
+			longValue(rrs.TTL),
+			stringValue(rrs.SetIdentifier),
+			longValue(rrs.Weight),
+			strings.Join(recordValues(rrs), ","),
+		})
+	}
+	return writeTable(w, color, rows)
+}
+
+// writeTable aligns rows into columns, the first row is the header
+func writeTable(w io.Writer, color bool, rows [][]string) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// color is applied after alignment so the escape codes don't skew the column widths
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if color && len(lines) > 0 {
+		lines[0] = ansiBold + strings.TrimSuffix(lines[0], "\n") + ansiReset + "\n"
+	}
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
+
+// printValues writes only the raw record values, one per line, for use by scripts
+func printValues(w io.Writer, sets []route53.ResourceRecordSet) error {
+	for _, rrs := range sets {
+		for _, rr := range rrs.ResourceRecords {
+			if _, err := fmt.Fprintln(w, stringValue(rr.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printZoneFile writes the record sets as master file lines, one per value: name TTL IN TYPE value.
+// Routing policies can't be expressed in a zone file, they are noted in a comment, and alias record sets are skipped.
+func printZoneFile(w io.Writer, sets []route53.ResourceRecordSet) error {
+	for _, rrs := range sets {
+		name, recordType := stringValue(rrs.Name), stringValue(rrs.Type)
+		if rrs.AliasTarget != nil {
+			if _, err := fmt.Fprintf(w, "; skipped alias record set %s %s\n", name, recordType); err != nil {
+				return err
+			}
+			continue
+		}
+		if rrs.SetIdentifier != nil {
+			if _, err := fmt.Fprintf(w, "; %s\n", describeRecordSet(rrs)); err != nil {
+				return err
+			}
+		}
+		for _, rr := range rrs.ResourceRecords {
+			value := stringValue(rr.Value)
+			if recordType == "TXT" && !strings.HasPrefix(value, `"`) {
+				value = strconv.Quote(value)
+			}
+			if _, err := fmt.Fprintf(w, "%s\t%s\tIN\t%s\t%s\n", name, longValue(rrs.TTL), recordType, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// batchEntries describes the record sets in the -file batch schema. Alias record sets have no batch form,
+// they fail rather than be left out, so applying the output never silently drops them.
+func batchEntries(sets []route53.ResourceRecordSet) ([]batchEntry, error) {
+	entries := []batchEntry{}
+	for _, rrs := range sets {
+		e, ok := entryFromRecordSet(rrs)
+		if !ok {
+			return nil, fmt.Errorf("alias record set %s %s can't be written as a -file batch entry, use -output=xml or -output=table", stringValue(rrs.Name), stringValue(rrs.Type))
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// printJSON writes the record sets using the -file batch schema, see batchEntries
+func printJSON(w io.Writer, sets []route53.ResourceRecordSet) error {
+	entries, err := batchEntries(sets)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, entries)
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// printYAML writes the record sets using the -file batch schema, so the output can be edited and applied with -file.
+// See batchEntries.
+func printYAML(w io.Writer, sets []route53.ResourceRecordSet) error {
+	entries, err := batchEntries(sets)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "[]")
+		return err
+	}
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// changeResponse is the serialized form of a ChangeResourceRecordSets response
+type changeResponse struct {
+	ID          string    `json:"id" yaml:"id"`
+	Status      string    `json:"status" yaml:"status"`
+	SubmittedAt time.Time `json:"submitted_at" yaml:"submitted_at"`
+	Comment     string    `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// printChangeInfo writes the ChangeInfo of a submitted change in the requested output format
+func printChangeInfo(w io.Writer, format string, info route53.ChangeInfo) error {
+	resp := changeResponse{
+		ID:          stringValue(info.ID),
+		Status:      stringValue(info.Status),
+		SubmittedAt: info.SubmittedAt.UTC(),
+		Comment:     stringValue(info.Comment),
+	}
+
+	switch format {
+	case outputTable:
+		return writeTable(w, colorEnabled(w), [][]string{
+			{"ID", "STATUS", "SUBMITTED", "COMMENT"},
+			{resp.ID, resp.Status, resp.SubmittedAt.Format(time.RFC3339), resp.Comment},
+		})
+	case outputValues:
+		_, err := fmt.Fprintln(w, resp.ID)
+		return err
+	case outputJSON:
+		return writeJSON(w, resp)
+	case outputYAML:
+		data, err := yaml.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPrintYAMLRoundTrip(t *testing.T) {
+	sets := routedRecordSets()
+	var buf bytes.Buffer
+	if err := printRecordSets(&buf, outputYAML, sets...); err != nil {
+		t.Fatal(err)
+	}
+	var entries []batchEntry
+	if err := yaml.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("%s\n%s", err, buf.String())
+	}
+	if len(entries) != len(sets) {
+		t.Fatalf("read %d entries, want %d\n%s", len(entries), len(sets), buf.String())
+	}
+	for i, e := range entries {
+		e.normalize()
+		if err := e.validate(); err != nil {
+			t.Errorf("entry %s: %s", e, err)
+		}
+		if got, want := canonicalRecordSet(e.recordSet()), canonicalRecordSet(sets[i]); got != want {
+			t.Errorf("read back\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestPrintBatchSchemaRejectsAlias(t *testing.T) {
+	alias := route53.ResourceRecordSet{
+		Name:        aws.String("www.example.com."),
+		Type:        aws.String("A"),
+		AliasTarget: &route53.AliasTarget{DNSName: aws.String("lb.example.net."), HostedZoneID: aws.String("Z2")},
+	}
+	for _, format := range []string{outputYAML, outputJSON} {
+		var buf bytes.Buffer
+		if err := printRecordSets(&buf, format, testRecordSet("a.example.com.", "A", 60, "192.0.2.1"), alias); err == nil {
+			t.Errorf("-output=%s wrote an alias record set without an error:\n%s", format, buf.String())
+		}
+	}
+}
+
+func TestZoneFileRoundTrip(t *testing.T) {
+	sets := []route53.ResourceRecordSet{
+		testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2", "192.0.2.3"),
+		testRecordSet("v6.example.com.", "AAAA", 60, "2001:db8::1"),
+		testRecordSet("alias.example.com.", "CNAME", 3600, "www.example.com."),
+		testRecordSet("example.com.", "MX", 300, "10 mx1.example.com.", "20 mx2.example.com."),
+		testRecordSet("example.com.", "TXT", 300, `"v=spf1 include:_spf.example.net ~all"`, `"a; quoted \"value\""`),
+		testRecordSet("example.com.", "CAA", 86400, `0 issue "ca.example; account=1"`, `0 iodef "mailto:security@example.com"`),
+	}
+	var buf bytes.Buffer
+	if err := printRecordSets(&buf, outputZonefile, sets...); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "example.com.zone")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	c, _, _ := newTestCLI(t, fake)
+	if err := c.importZone("Z1", "example.com.", path, false); err != nil {
+		t.Fatalf("%s\n%s", err, buf.String())
+	}
+	imported := make(map[string]string)
+	for _, rrs := range fake.sets["Z1"] {
+		imported[stringValue(rrs.Name)+" "+stringValue(rrs.Type)] = canonicalRecordSet(rrs)
+	}
+	if len(imported) != len(sets) {
+		t.Errorf("imported %d record sets, want %d\n%s", len(imported), len(sets), buf.String())
+	}
+	for _, rrs := range sets {
+		key := stringValue(rrs.Name) + " " + stringValue(rrs.Type)
+		if got, want := imported[key], canonicalRecordSet(rrs); got != want {
+			t.Errorf("%s imported as\n%s\nwant\n%s\nfrom\n%s", key, got, want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPreflightPort    = 80
+	defaultPreflightTimeout = 2 * time.Second
+)
+
+// preflight checks that endpoints answer before they are added to a record set
+type preflight struct {
+	mode    string // "tcp" or "http", empty disables the check
+	port    int
+	timeout time.Duration
+	// warnOnly adds unresponsive endpoints anyway after logging a warning
+	warnOnly bool
+}
+
+// check probes a single IP, returning nil when it responds
+func (p preflight) check(ip string) error {
+	addr := net.JoinHostPort(ip, strconv.Itoa(p.port))
+	switch p.mode {
+	case "http":
+		client := &http.Client{Timeout: p.timeout}
+		resp, err := client.Get("http://" + addr + "/")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	default:
+		conn, err := net.DialTimeout("tcp", addr, p.timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// preflightIPs probes the IPs concurrently and returns the ones that may be added.
+// Unresponsive IPs fail the whole operation unless the preflight is warn-only, in which case they are kept.
+func (c *cli) preflightIPs(ips []string) ([]string, error) {
+	if c.preflight.mode == "" {
+		return ips, nil
+	}
+	errs := make([]error, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			errs[i] = c.preflight.check(ip)
+		}(i, ip)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, ip := range ips {
+		if errs[i] == nil {
+			if c.verbose {
+				c.log.Printf("preflight %s check of %s:%d ok\n", c.preflight.mode, ip, c.preflight.port)
+			}
+			continue
+		}
+		c.log.Printf("WARNING preflight %s check of %s:%d failed: %s\n", c.preflight.mode, ip, c.preflight.port, errs[i])
+		failed = append(failed, ip)
+	}
+	if len(failed) > 0 && !c.preflight.warnOnly {
+		return nil, fmt.Errorf("refusing to add unresponsive IPs %v", failed)
+	}
+	return ips, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// setWeight is the weight rebalance gives the weighted record set with a set identifier
+type setWeight struct {
+	setID  string
+	weight int64
+}
+
+// parseSetWeights reads setid=weight arguments
+func parseSetWeights(args []string) ([]setWeight, error) {
+	var weights []setWeight
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		i := strings.LastIndex(arg, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%q is not a setid=weight pair", arg)
+		}
+		setID := arg[:i]
+		weight, err := strconv.ParseInt(arg[i+1:], 10, 64)
+		if err != nil || weight < 0 || weight > maxWeight {
+			return nil, fmt.Errorf("weight of %s must be a number between 0 and %d", setID, maxWeight)
+		}
+		if seen[setID] {
+			return nil, fmt.Errorf("set identifier %s is given more than once", setID)
+		}
+		seen[setID] = true
+		weights = append(weights, setWeight{setID: setID, weight: weight})
+	}
+	return weights, nil
+}
+
+// rebalance sets the weights of weighted record sets sharing a name and type in one atomic batch,
+// so traffic shifts between them at once. Every set identifier must exist and be weighted.
+func (c *cli) rebalance(zoneID, recordName, recordType string, weights []setWeight) error {
+	sets, err := c.recordSetsByName(zoneID, recordName, recordType)
+	if err != nil {
+		return fmt.Errorf("getting resource record sets %w", err)
+	}
+	bySetID := make(map[string]route53.ResourceRecordSet)
+	for _, rrs := range sets {
+		bySetID[stringValue(rrs.SetIdentifier)] = rrs
+	}
+
+	var changes []route53.Change
+	final := make(map[string]int64)
+	for _, rrs := range sets {
+		if rrs.Weight != nil {
+			final[stringValue(rrs.SetIdentifier)] = *rrs.Weight
+		}
+	}
+	for _, w := range weights {
+		rrs, exists := bySetID[w.setID]
+		switch {
+		case !exists:
+			return fmt.Errorf("%s %s has no record set with setIdentifier=%s", recordName, recordType, w.setID)
+		case rrs.Weight == nil:
+			return fmt.Errorf("%s %s setIdentifier=%s is not a weighted record set", recordName, recordType, w.setID)
+		case *rrs.Weight == w.weight:
+			continue
+		}
+		if c.verbose {
+			c.log.Printf("setIdentifier=%s weight %d -> %d\n", w.setID, *rrs.Weight, w.weight)
+		}
+		updated := copyResourceRecordSet(rrs)
+		updated.Weight = aws.Long(w.weight)
+		if err := c.validateRecordSet(updated); err != nil {
+			return err
+		}
+		prior := rrs
+		c.notePrior(&updated, &prior)
+		changes = append(changes, route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &updated})
+		final[w.setID] = w.weight
+	}
+	if len(changes) == 0 {
+		c.log.Println("no change needed")
+		return nil
+	}
+
+	var total int64
+	for _, weight := range final {
+		total += weight
+	}
+	if total == 0 {
+		c.log.Printf("WARNING every record set of %s %s would have weight 0, Route53 then answers with all of them equally\n", recordName, recordType)
+	}
+	if err := c.submitChanges(zoneID, changes); err != nil {
+		return err
+	}
+	var shares []string
+	for _, rrs := range sets {
+		setID := stringValue(rrs.SetIdentifier)
+		weight, ok := final[setID]
+		switch {
+		case !ok:
+		case total > 0:
+			shares = append(shares, fmt.Sprintf("%s=%d (%.0f%%)", setID, weight, float64(weight)*100/float64(total)))
+		default:
+			shares = append(shares, fmt.Sprintf("%s=%d", setID, weight))
+		}
+	}
+	verb := "rebalanced"
+	if c.dryRun {
+		verb = "would rebalance"
+	}
+	c.log.Printf("%s %s %s: %s\n", verb, recordName, recordType, strings.Join(shares, " "))
+	return nil
+}
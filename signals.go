@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// watchSignals cancels the run's context on the first SIGINT or SIGTERM, so no further API calls are made.
+// A second signal exits immediately.
+func (c *cli) watchSignals() {
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.ctx = ctx
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+		<-sigs
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// interrupted reports whether a shutdown signal has been received
+func (c *cli) interrupted() bool {
+	return c.ctx.Err() == context.Canceled
+}
+
+// reportInterrupted logs what was already submitted to Route53 before the run was stopped
+func (c *cli) reportInterrupted(pending int) {
+	c.log.Printf("interrupted: %d change(s) submitted, %d change(s) not submitted\n", c.submittedChanges, pending)
+	for _, id := range c.changeIDs {
+		c.log.Printf("submitted changeID=%s\n", id)
+	}
+}
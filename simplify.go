@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// simpleRecordSet returns a copy of rrs without its set identifier and routing policy, keeping the values and TTL
+func simpleRecordSet(rrs route53.ResourceRecordSet) route53.ResourceRecordSet {
+	simple := copyResourceRecordSet(rrs)
+	simple.SetIdentifier = nil
+	simple.Weight = nil
+	simple.Region = nil
+	simple.Failover = nil
+	simple.GeoLocation = nil
+	simple.MultiValueAnswer = nil
+	simple.HealthCheckID = nil
+	return simple
+}
+
+// simplify replaces a record set using a routing policy with a simple one holding the same values and TTL, the reverse of convert.
+// The set identifier is part of the record set's key so this needs a DELETE and CREATE, sent in one atomic batch.
+// Route53 doesn't allow a simple record set next to others with the same name and type, so it must be the last one.
+func (c *cli) simplify(zoneID string, routed route53.ResourceRecordSet) error {
+	name, recordType := stringValue(routed.Name), stringValue(routed.Type)
+	if routed.SetIdentifier == nil {
+		return fmt.Errorf("%s %s is already a simple record set", name, recordType)
+	}
+	sets, err := c.recordSetsByName(zoneID, name, recordType)
+	if err != nil {
+		return err
+	}
+	if len(sets) > 1 {
+		return fmt.Errorf("%s %s has %d record sets, delete the others before simplifying setIdentifier=%s", name, recordType, len(sets), *routed.SetIdentifier)
+	}
+
+	simple := simpleRecordSet(routed)
+	if err := c.validateRecordSet(simple); err != nil {
+		return err
+	}
+	if err := c.confirmZone(zoneID, fmt.Sprintf("replace %s %s %s setIdentifier=%s with a simple record set?", name, recordType, routingPolicy(routed), *routed.SetIdentifier)); err != nil {
+		return err
+	}
+	c.backup(routed)
+	changes := []route53.Change{
+		{Action: aws.String("DELETE"), ResourceRecordSet: &routed},
+		{Action: aws.String("CREATE"), ResourceRecordSet: &simple},
+	}
+	if err := c.submitAtomic(zoneID, changes); err != nil {
+		return err
+	}
+	if !c.dryRun {
+		c.log.Printf("simplified %s %s, removed setIdentifier=%s\n", name, recordType, *routed.SetIdentifier)
+	}
+	return nil
+}
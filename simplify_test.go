@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+)
+
+func TestSimplifyIsOneBatch(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	routed := testRecordSet("www.example.com.", "A", 300, "192.0.2.1")
+	routed.SetIdentifier = aws.String("dc1")
+	routed.Weight = aws.Long(10)
+	fake.add("Z1", routed)
+	c, _, _ := newTestCLI(t, fake)
+	c.assumeYes = true
+	c.batchSize = 1
+	if err := c.simplify("Z1", routed); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.requests) != 1 {
+		t.Fatalf("%d ChangeResourceRecordSets calls, want 1", len(fake.requests))
+	}
+	want := []string{"DELETE www.example.com. A", "CREATE www.example.com. A"}
+	if got := changesOf(fake.requests); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+var errTimeout = errors.New("timed out, -timeout deadline exceeded")
+
+// timeoutGrace is how long an API call already in flight at the -timeout deadline may still take
+const timeoutGrace = 10 * time.Second
+
+// startTimeout bounds the whole run. The AWS client can't cancel calls, so the deadline is
+// checked before each API call and a watchdog exits if a call is still hung after the grace period.
+func (c *cli) startTimeout(timeout time.Duration) context.CancelFunc {
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	c.ctx = ctx
+	watchdog := time.AfterFunc(timeout+timeoutGrace, func() {
+		c.log.Printf("ERROR timed out after %s waiting on an AWS API call\n", timeout)
+		// zones may still be submitted concurrently
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, id := range c.changeIDs {
+			c.log.Printf("submitted changeID=%s\n", id)
+		}
+		os.Exit(exitTimeout)
+	})
+	return func() {
+		watchdog.Stop()
+		cancel()
+	}
+}
+
+// checkDeadline returns an error once the run's context is done, because the -timeout deadline
+// passed or a shutdown signal was received. Call it before each API call and between pages.
+func (c *cli) checkDeadline() error {
+	switch c.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return errTimeout
+	case context.Canceled:
+		return errInterrupted
+	}
+	return c.ctx.Err()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPaginationStopsWhenCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		list func(c *cli) error
+	}{
+		{"listResourceRecordSets", func(c *cli) error {
+			_, err := c.listResourceRecordSets("Z1", recordSetFilter{})
+			return err
+		}},
+		{"recordSetsByName", func(c *cli) error {
+			_, err := c.recordSetsByName("Z1", "www.example.com.", "")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+			for _, recordType := range []string{"A", "AAAA", "MX", "TXT", "CAA"} {
+				fake.add("Z1", testRecordSet("www.example.com.", recordType, 300, "value"))
+			}
+			fake.pageSize = 1
+			c, _, _ := newTestCLI(t, fake)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			c.ctx = ctx
+			// cancel while the second page is being fetched, as a signal arriving mid-listing would
+			fake.onListRecords = func() {
+				if fake.listRecordsCalls == 2 {
+					cancel()
+				}
+			}
+			if err := tt.list(c); err != errInterrupted {
+				t.Errorf("error %v, want %v", err, errInterrupted)
+			}
+			if fake.listRecordsCalls != 2 {
+				t.Errorf("%d ListResourceRecordSets calls, want 2 with none after the cancellation", fake.listRecordsCalls)
+			}
+		})
+	}
+}
+
+func TestCheckDeadline(t *testing.T) {
+	c, _, _ := newTestCLI(t, newFakeRoute53(nil))
+	if err := c.checkDeadline(); err != nil {
+		t.Errorf("before the deadline: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	c.ctx = ctx
+	if err := c.checkDeadline(); err != errTimeout {
+		t.Errorf("after the deadline: %v, want %v", err, errTimeout)
+	}
+	if _, err := c.getResourceRecordSet("Z1", "www.example.com.", "A", ""); err != errTimeout {
+		t.Errorf("getResourceRecordSet after the deadline: %v, want %v", err, errTimeout)
+	}
+}
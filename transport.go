@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// userAgentTransport sets the User-Agent header on every request so API calls can be attributed to this tool
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("User-Agent", t.userAgent)
+	atomic.AddInt64(&apiCalls, 1)
+	return t.next.RoundTrip(r)
+}
+
+// newHTTPClient returns the client used for AWS API calls
+func newHTTPClient(userAgent string) *http.Client {
+	return &http.Client{Transport: userAgentTransport{userAgent: userAgent, next: http.DefaultTransport}}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+const (
+	undoLogFile = "undo_log.json"
+	// maxUndoEntries bounds the undo log, the oldest change batches are forgotten first
+	maxUndoEntries = 200
+)
+
+// undoEntry is a change batch applied to a zone along with the changes reversing it
+type undoEntry struct {
+	ZoneID  string           `json:"zone_id"`
+	At      time.Time        `json:"at"`
+	Applied []route53.Change `json:"applied"`
+	Inverse []route53.Change `json:"inverse"`
+}
+
+// undoLog is the local log of applied change batches -cmd=undo reverses
+type undoLog struct {
+	path    string
+	Entries []undoEntry `json:"entries"`
+}
+
+// loadUndoLog reads the undo log, a missing file is treated as empty
+func loadUndoLog() (*undoLog, error) {
+	l := &undoLog{path: filepath.Join(stateDir(), undoLogFile)}
+	data, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// save writes the undo log, keeping the newest maxUndoEntries entries
+func (l *undoLog) save() error {
+	if len(l.Entries) > maxUndoEntries {
+		l.Entries = l.Entries[len(l.Entries)-maxUndoEntries:]
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), stateDirPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.path, data, stateFilePermissions)
+}
+
+// notePrior remembers the record set the UPSERT of rrs overwrites, nil when the UPSERT creates it,
+// so inverseChanges doesn't look up again what the caller already fetched
+func (c *cli) notePrior(rrs, prior *route53.ResourceRecordSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.priorStates == nil {
+		c.priorStates = make(map[*route53.ResourceRecordSet]*route53.ResourceRecordSet)
+	}
+	c.priorStates[rrs] = prior
+}
+
+// knownPrior returns and forgets the record set noted for the UPSERT of rrs, the second result is false when none was
+func (c *cli) knownPrior(rrs *route53.ResourceRecordSet) (*route53.ResourceRecordSet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prior, known := c.priorStates[rrs]
+	delete(c.priorStates, rrs)
+	return prior, known
+}
+
+// inverseChanges builds the changes reversing batch: a CREATE is undone by a DELETE and a DELETE by
+// a CREATE, an UPSERT by restoring the record set it overwrites, or deleting it when it was new.
+// The overwritten record set is the one noted by notePrior, it's only looked up when no caller did.
+func (c *cli) inverseChanges(zoneID string, batch []route53.Change) ([]route53.Change, error) {
+	var inverse []route53.Change
+	for _, change := range batch {
+		rrs := change.ResourceRecordSet
+		if rrs == nil {
+			continue
+		}
+		switch strings.ToUpper(stringValue(change.Action)) {
+		case "CREATE":
+			inverse = append(inverse, route53.Change{Action: aws.String("DELETE"), ResourceRecordSet: rrs})
+		case "DELETE":
+			inverse = append(inverse, route53.Change{Action: aws.String("CREATE"), ResourceRecordSet: rrs})
+		default:
+			if prior, known := c.knownPrior(rrs); known {
+				if prior == nil {
+					inverse = append(inverse, route53.Change{Action: aws.String("DELETE"), ResourceRecordSet: rrs})
+				} else {
+					inverse = append(inverse, route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: prior})
+				}
+				continue
+			}
+			prior, err := c.getResourceRecordSet(zoneID, stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+			switch {
+			case isNotFound(err):
+				inverse = append(inverse, route53.Change{Action: aws.String("DELETE"), ResourceRecordSet: rrs})
+			case err != nil:
+				return nil, err
+			default:
+				inverse = append(inverse, route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: &prior})
+			}
+		}
+	}
+	return inverse, nil
+}
+
+// recordUndo appends an applied change batch and its inverse to the undo log
+func (c *cli) recordUndo(zoneID string, batch, inverse []route53.Change) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, err := loadUndoLog()
+	if err != nil {
+		return err
+	}
+	l.Entries = append(l.Entries, undoEntry{ZoneID: zoneID, At: time.Now().UTC(), Applied: batch, Inverse: inverse})
+	return l.save()
+}
+
+// undo reverses the last count change batches logged for the zone, newest first.
+// A batch is only reversed while its record sets are still as it left them, unless -force,
+// so changes made since aren't silently lost. Reversals are not logged themselves.
+func (c *cli) undo(zone zoneRef, count int) error {
+	l, err := loadUndoLog()
+	if err != nil {
+		return fmt.Errorf("reading the undo log %w", err)
+	}
+	var picked []undoEntry
+	for i := len(l.Entries) - 1; i >= 0 && len(picked) < count; i-- {
+		if l.Entries[i].ZoneID == zone.id {
+			picked = append(picked, l.Entries[i])
+		}
+	}
+	if len(picked) == 0 {
+		return fmt.Errorf("no logged changes to undo in %s", zone.name)
+	}
+	if len(picked) < count {
+		c.log.Printf("only %d change batch(es) are logged for %s\n", len(picked), zone.name)
+	}
+	if err := c.confirmZone(zone.id, fmt.Sprintf("undo the last %d change batch(es) applied to %s?", len(picked), zone.name)); err != nil {
+		return err
+	}
+
+	c.undoing = true
+	defer func() { c.undoing = false }()
+	for _, entry := range picked {
+		if !c.force {
+			if err := c.checkUnchangedSince(zone.id, entry); err != nil {
+				return err
+			}
+		}
+		if err := c.submitChanges(zone.id, entry.Inverse); err != nil {
+			return fmt.Errorf("undoing the changes applied at %s %w", entry.At.Format(time.RFC3339), err)
+		}
+		if c.dryRun {
+			continue
+		}
+		if err := c.forgetUndo(entry); err != nil {
+			c.log.Println("WARNING could not update the undo log", err)
+		}
+		c.log.Printf("undid %d change(s) applied to %s at %s\n", len(entry.Applied), zone.name, entry.At.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkUnchangedSince fails when a record set the entry changed is no longer in the state the entry left it in
+func (c *cli) checkUnchangedSince(zoneID string, entry undoEntry) error {
+	for _, change := range entry.Applied {
+		rrs := change.ResourceRecordSet
+		if rrs == nil {
+			continue
+		}
+		live, err := c.getResourceRecordSet(zoneID, stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier))
+		deleted := strings.ToUpper(stringValue(change.Action)) == "DELETE"
+		switch {
+		case isNotFound(err) && deleted:
+		case err != nil && !isNotFound(err):
+			return err
+		case isNotFound(err) || deleted || !sameResourceRecordSet(live, *rrs):
+			return fmt.Errorf("%s %s setIdentifier=%s changed after %s, use -force to undo anyway",
+				stringValue(rrs.Name), stringValue(rrs.Type), stringValue(rrs.SetIdentifier), entry.At.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// forgetUndo removes an undone entry from the undo log
+func (c *cli) forgetUndo(undone undoEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, err := loadUndoLog()
+	if err != nil {
+		return err
+	}
+	for i, entry := range l.Entries {
+		if entry.ZoneID == undone.ZoneID && entry.At.Equal(undone.At) {
+			l.Entries = append(l.Entries[:i], l.Entries[i+1:]...)
+			break
+		}
+	}
+	return l.save()
+}
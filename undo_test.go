@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+)
+
+// zoneState renders the record sets of a fake zone for comparison, see canonicalRecordSet
+func zoneState(fake *fakeRoute53, zoneID string) map[string]bool {
+	state := make(map[string]bool)
+	for _, rrs := range fake.sets[zoneID] {
+		state[canonicalRecordSet(rrs)] = true
+	}
+	return state
+}
+
+func sameZoneState(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUndoRestoresPreviousValues(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	current := testRecordSet("www.example.com.", "A", 300, "192.0.2.1", "192.0.2.2")
+	fake.add("Z1", current)
+	before := zoneState(fake, "Z1")
+	c, _, _ := newTestCLI(t, fake)
+	c.assumeYes = true
+
+	updated := testRecordSet("www.example.com.", "A", 60, "192.0.2.3")
+	if err := c.upsertResourceRecordSet("Z1", current, updated); err != nil {
+		t.Fatal(err)
+	}
+	if fake.listRecordsCalls != 0 {
+		t.Errorf("%d ListResourceRecordSets calls submitting an UPSERT of a fetched record set, want none", fake.listRecordsCalls)
+	}
+	if sameZoneState(before, zoneState(fake, "Z1")) {
+		t.Fatal("the UPSERT didn't change the zone")
+	}
+
+	if err := c.undo(zoneRef{id: "Z1", name: "example.com."}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !sameZoneState(before, zoneState(fake, "Z1")) {
+		t.Errorf("after undo the zone holds %v, want %v", fake.sets["Z1"], before)
+	}
+}
+
+func TestUndoBatchUpserts(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z1": "example.com."})
+	fake.add("Z1", testRecordSet("www.example.com.", "A", 300, "192.0.2.1"))
+	before := zoneState(fake, "Z1")
+	c, _, _ := newTestCLI(t, fake)
+	c.assumeYes = true
+
+	entries := []batchEntry{
+		{Name: "www.example.com", Values: []string{"192.0.2.9"}},
+		{Name: "new.example.com", Values: []string{"192.0.2.10"}},
+	}
+	for i := range entries {
+		entries[i].normalize()
+	}
+	if result := c.runBatch(entries, "Z1"); len(result.errs) > 0 || result.applied != 2 {
+		t.Fatalf("applied %d, errors %v", result.applied, result.errs)
+	}
+	// a lookup per entry and the CNAME conflict check of the new record set, none more for the undo log
+	if want := len(entries) + 1; fake.listRecordsCalls != want {
+		t.Errorf("%d ListResourceRecordSets calls, want %d", fake.listRecordsCalls, want)
+	}
+
+	if err := c.undo(zoneRef{id: "Z1", name: "example.com."}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !sameZoneState(before, zoneState(fake, "Z1")) {
+		t.Errorf("after undo the zone holds %v, want %v", fake.sets["Z1"], before)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// validateValue checks a record value is well formed for the record type
+func validateValue(recordType, value string) error {
+	switch normalizeType(recordType) {
+	case "A":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("%q is not an IPv4 address", value)
+		}
+	case "AAAA":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("%q is not an IPv6 address", value)
+		}
+	case "CNAME":
+		if value == "" || strings.ContainsAny(value, " \t") {
+			return fmt.Errorf("%q is not a host name", value)
+		}
+	case "CAA":
+		if _, _, _, err := parseCAA(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseCAA splits a CAA value of the form flags tag "value", e.g. 0 issue "letsencrypt.org".
+// The value may be given without quotes when it holds no whitespace.
+func parseCAA(value string) (flags int, tag, caaValue string, err error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return 0, "", "", fmt.Errorf("%q is not a CAA value, expected flags tag \"value\", e.g. 0 issue \"letsencrypt.org\"", value)
+	}
+	flags, err = strconv.Atoi(fields[0])
+	if err != nil || flags < 0 || flags > 255 {
+		return 0, "", "", fmt.Errorf("CAA flags %q must be a number between 0 and 255", fields[0])
+	}
+	tag = strings.ToLower(fields[1])
+	for _, r := range tag {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return 0, "", "", fmt.Errorf("CAA tag %q must only hold letters and digits", fields[1])
+		}
+	}
+	rest := strings.Join(fields[2:], " ")
+	switch {
+	case len(rest) >= 2 && strings.HasPrefix(rest, `"`) && strings.HasSuffix(rest, `"`):
+		caaValue = rest[1 : len(rest)-1]
+	case len(fields) == 3 && !strings.Contains(rest, `"`):
+		caaValue = rest
+	default:
+		return 0, "", "", fmt.Errorf("CAA value %s must be quoted", rest)
+	}
+	if strings.Contains(caaValue, `"`) {
+		return 0, "", "", fmt.Errorf("CAA value %s must not hold quotes", rest)
+	}
+	return flags, tag, caaValue, nil
+}
+
+// canonicalValue writes a record value the way Route53 returns it, so values given differently compare equal.
+// CAA values get a lower case tag and a quoted value, other values are returned as they are.
+func canonicalValue(recordType, value string) string {
+	if normalizeType(recordType) != "CAA" {
+		return value
+	}
+	flags, tag, caaValue, err := parseCAA(value)
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("%d %s \"%s\"", flags, tag, caaValue)
+}
+
+// splitUnquoted is strings.SplitN splitting only at a sep outside double quotes,
+// so a quoted CAA value like 0 issue "ca.example; account=1" stays whole
+func splitUnquoted(s string, sep rune, n int) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted && (n < 0 || len(parts) < n-1):
+			parts = append(parts, s[start:i])
+			start = i + len(string(sep))
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// maxSetIDLength is the longest set identifier Route53 accepts
+const maxSetIDLength = 128
+
+// validateSetID checks a set identifier is within Route53's length limit and holds only printable characters
+func validateSetID(setID string) error {
+	if len(setID) > maxSetIDLength {
+		return fmt.Errorf("set identifier is %d characters long, the maximum is %d", len(setID), maxSetIDLength)
+	}
+	for _, r := range setID {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf("set identifier %q holds %q, only printable ASCII characters are allowed", setID, r)
+		}
+	}
+	return nil
+}
+
+// swapPair replaces one record value with another
+type swapPair struct {
+	old string
+	new string
+}
+
+// parseSwapPairs reads old=new arguments
+func parseSwapPairs(args []string) ([]swapPair, error) {
+	var pairs []swapPair
+	for _, arg := range args {
+		kv := splitUnquoted(arg, '=', 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("%q is not an old=new pair", arg)
+		}
+		pairs = append(pairs, swapPair{old: kv[0], new: kv[1]})
+	}
+	return pairs, nil
+}
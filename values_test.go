@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCAA(t *testing.T) {
+	tests := []struct {
+		value     string
+		flags     int
+		tag       string
+		caaValue  string
+		canonical string
+		err       bool
+	}{
+		{value: `0 issue "letsencrypt.org"`, tag: "issue", caaValue: "letsencrypt.org", canonical: `0 issue "letsencrypt.org"`},
+		{value: `0 ISSUE letsencrypt.org`, tag: "issue", caaValue: "letsencrypt.org", canonical: `0 issue "letsencrypt.org"`},
+		{value: `128 iodef "mailto:security@example.com"`, flags: 128, tag: "iodef", caaValue: "mailto:security@example.com", canonical: `128 iodef "mailto:security@example.com"`},
+		{value: `0 issue "ca.example; account=1"`, tag: "issue", caaValue: "ca.example; account=1", canonical: `0 issue "ca.example; account=1"`},
+		{value: `0 issue "ca.example, policy=ev"`, tag: "issue", caaValue: "ca.example, policy=ev", canonical: `0 issue "ca.example, policy=ev"`},
+		{value: `0 issue ";"`, tag: "issue", caaValue: ";", canonical: `0 issue ";"`},
+		{value: `0 issue`, err: true},
+		{value: `256 issue "ca.example"`, err: true},
+		{value: `0 is-sue "ca.example"`, err: true},
+		{value: `0 issue ca.example account=1`, err: true},
+		{value: `0 issue "ca."example"`, err: true},
+	}
+	for _, tt := range tests {
+		flags, tag, caaValue, err := parseCAA(tt.value)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseCAA(%q) = %d %q %q, want an error", tt.value, flags, tag, caaValue)
+			}
+			continue
+		}
+		if err != nil || flags != tt.flags || tag != tt.tag || caaValue != tt.caaValue {
+			t.Errorf("parseCAA(%q) = %d %q %q %v, want %d %q %q", tt.value, flags, tag, caaValue, err, tt.flags, tt.tag, tt.caaValue)
+		}
+		if got := canonicalValue("caa", tt.value); got != tt.canonical {
+			t.Errorf("canonicalValue(%q) = %q, want %q", tt.value, got, tt.canonical)
+		}
+	}
+}
+
+func TestSplitValuesKeepsQuotedCAA(t *testing.T) {
+	args := []string{`0 issue "ca.example, policy=ev",0 issuewild ";"`, "192.0.2.1,192.0.2.2"}
+	want := []string{`0 issue "ca.example, policy=ev"`, `0 issuewild ";"`, "192.0.2.1", "192.0.2.2"}
+	if got := splitValues(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitValues(%q) = %q, want %q", args, got, want)
+	}
+}
+
+func TestCSVKeepsQuotedCAA(t *testing.T) {
+	input := "name,type,values\n" +
+		`example.com,CAA,"0 issue ""ca.example; account=1"";0 iodef ""mailto:security@example.com"""` + "\n"
+	entries, err := parseCSVBatch(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`0 issue "ca.example; account=1"`, `0 iodef "mailto:security@example.com"`}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Values, want) {
+		t.Fatalf("entries = %+v, want one with values %q", entries, want)
+	}
+}
+
+func TestParseSwapPairsQuotedCAA(t *testing.T) {
+	pairs, err := parseSwapPairs([]string{`0 issue "ca.example; account=1"=0 issue "ca.example; account=2"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []swapPair{{old: `0 issue "ca.example; account=1"`, new: `0 issue "ca.example; account=2"`}}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("pairs = %+v, want %+v", pairs, want)
+	}
+}
+
+func TestSwapChangeComparesCanonicalCAA(t *testing.T) {
+	c, _, _ := newTestCLI(t, newFakeRoute53(nil))
+	rrs := testRecordSet("example.com.", "CAA", 300, `0 issue "letsencrypt.org"`)
+	// given unquoted and with an upper case tag, the way Route53 doesn't return it
+	change, err := c.swapChange(rrs, []swapPair{{old: "0 ISSUE letsencrypt.org", new: "0 issue ca.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := recordValues(*change.ResourceRecordSet)
+	if want := []string{`0 issue "ca.example"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("values after the swap = %q, want %q", got, want)
+	}
+	if _, err := c.swapChange(rrs, []swapPair{{old: `0 issue "letsencrypt.org"`, new: "0 ISSUE letsencrypt.org"}}); err == nil || !strings.Contains(err.Error(), "already in the record set") {
+		t.Errorf("swapping in a value already present given differently = %v, want an error", err)
+	}
+}
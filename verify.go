@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+const (
+	// verifyTimeout is how long -verify waits for a change to show up on the zone's name servers
+	verifyTimeout  = 90 * time.Second
+	verifyInterval = 5 * time.Second
+)
+
+// zoneNameServers returns the authoritative Route53 name servers of a hosted zone
+func (c *cli) zoneNameServers(zoneID string) ([]string, error) {
+	if err := c.checkDeadline(); err != nil {
+		return nil, err
+	}
+	resp, err := c.r53.GetHostedZone(&route53.GetHostedZoneRequest{ID: aws.String(zoneID)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.DelegationSet == nil || len(resp.DelegationSet.NameServers) == 0 {
+		return nil, fmt.Errorf("zoneID=%s has no name servers, private zones can't be verified", zoneID)
+	}
+	return resp.DelegationSet.NameServers, nil
+}
+
+// verifyResolves checks the zone's name servers answer name with every expected value.
+// Weighted and multivalue record sets only answer with some of the values at a time, so the
+// answers are collected over several queries until all values were seen or verifyTimeout passes.
+func (c *cli) verifyResolves(zoneID, name, recordType string, expected []string) error {
+	servers, err := c.zoneNameServers(zoneID)
+	if err != nil {
+		return err
+	}
+	key := func(value string) string {
+		if recordType == "CNAME" {
+			return strings.ToLower(normalizeName(value))
+		}
+		return value
+	}
+	seen := make(map[string]struct{})
+	deadline := time.Now().Add(verifyTimeout)
+	for attempt := 0; ; attempt++ {
+		server := servers[attempt%len(servers)]
+		values, err := lookupValues(server, recordType, name)
+		if err != nil {
+			c.log.Printf("WARNING resolving %s at %s: %s\n", name, server, err)
+		}
+		for _, v := range values {
+			seen[key(v)] = struct{}{}
+		}
+		var missing []string
+		for _, v := range expected {
+			if _, ok := seen[key(v)]; !ok {
+				missing = append(missing, v)
+			}
+		}
+		if len(missing) == 0 {
+			c.log.Printf("verified %s %s resolves to %s at %s\n", name, recordType, strings.Join(expected, ","), server)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			got := mapKeys(seen)
+			sort.Strings(got)
+			return fmt.Errorf("verifying %s: %s not served by the zone's name servers after %s, seen %s", name, strings.Join(missing, ","), verifyTimeout, strings.Join(got, ","))
+		}
+		if err := c.checkDeadline(); err != nil {
+			return err
+		}
+		time.Sleep(verifyInterval)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// defaultWatchInterval is how often watch lists the record set when -interval isn't given
+const defaultWatchInterval = 30 * time.Second
+
+// watchEvent is a change of a watched record set, written as one JSON object per line with -output=json
+type watchEvent struct {
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	SetID   string    `json:"setid,omitempty"`
+	Created bool      `json:"created,omitempty"`
+	Deleted bool      `json:"deleted,omitempty"`
+	Added   []string  `json:"added,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	OldTTL  int64     `json:"old_ttl,omitempty"`
+	NewTTL  int64     `json:"new_ttl,omitempty"`
+	Routing string    `json:"routing,omitempty"`
+}
+
+// watch lists the record set every interval and prints a timestamped diff whenever its values, TTL or routing change,
+// until the run is interrupted. A record set that doesn't exist (yet) is watched for being created.
+func (c *cli) watch(zoneID, recordName, recordType, setID string, interval time.Duration, format string) error {
+	entry := batchEntry{Name: recordName, Type: recordType, SetID: setID}
+	last, err := c.watchedRecordSet(zoneID, entry)
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		c.log.Printf("%s does not exist, watching for it to be created every %s\n", entry, interval)
+	} else {
+		c.log.Printf("watching %s every %s, press Ctrl-C to stop\n", entry, interval)
+		if err := printRecordSets(c.out, format, *last); err != nil {
+			return fmt.Errorf("writing output %w", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			if c.interrupted() {
+				c.log.Println("stopped watching")
+				return nil
+			}
+			return c.checkDeadline()
+		case <-ticker.C:
+		}
+		current, err := c.watchedRecordSet(zoneID, entry)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				continue
+			}
+			c.log.Println("WARNING listing record set failed, retrying at the next interval:", err)
+			continue
+		}
+		d := diffRecordSets(entry, last, current)
+		if d.drifted() {
+			if err := printWatchDiff(c.out, format, time.Now(), d); err != nil {
+				return fmt.Errorf("writing output %w", err)
+			}
+		}
+		last = current
+	}
+}
+
+// watchedRecordSet gets the watched record set, nil when it doesn't exist
+func (c *cli) watchedRecordSet(zoneID string, e batchEntry) (*route53.ResourceRecordSet, error) {
+	rrs, err := c.getResourceRecordSet(zoneID, e.Name, e.Type, e.SetID)
+	switch {
+	case isNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &rrs, nil
+}
+
+// printWatchDiff writes a change seen by watch, as a JSON line with -output=json and in the diff format otherwise
+func printWatchDiff(w io.Writer, format string, at time.Time, d recordSetDiff) error {
+	if format == outputJSON {
+		event := watchEvent{
+			Time:    at,
+			Name:    d.entry.Name,
+			Type:    d.entry.Type,
+			SetID:   d.entry.SetID,
+			Created: d.create,
+			Deleted: d.remove,
+			Added:   d.added,
+			Removed: d.removed,
+		}
+		if d.oldTTL != d.newTTL {
+			event.OldTTL, event.NewTTL = d.oldTTL, d.newTTL
+		}
+		if d.routing {
+			event.Routing = describeRouting(*d.desired)
+		}
+		return json.NewEncoder(w).Encode(event)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", at.Format(time.RFC3339)); err != nil {
+		return err
+	}
+	printDiffs(w, []recordSetDiff{d})
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+const webhookTimeout = 5 * time.Second
+
+// changeSummary describes one change of a submitted batch
+type changeSummary struct {
+	Action string   `json:"action"`
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	SetID  string   `json:"setid,omitempty"`
+	TTL    int64    `json:"ttl,omitempty"`
+	Values []string `json:"values"`
+}
+
+// changeEvent is the JSON document posted to -webhook for every submitted change batch
+type changeEvent struct {
+	ZoneID   string          `json:"zone_id"`
+	ChangeID string          `json:"change_id"`
+	Status   string          `json:"status"`
+	User     string          `json:"user"`
+	Time     time.Time       `json:"time"`
+	Changes  []changeSummary `json:"changes"`
+}
+
+// summarizeChanges describes the changes of a batch for reporting
+func summarizeChanges(changes []route53.Change) []changeSummary {
+	var summaries []changeSummary
+	for _, change := range changes {
+		s := changeSummary{Action: stringValue(change.Action)}
+		if rrs := change.ResourceRecordSet; rrs != nil {
+			s.Name = stringValue(rrs.Name)
+			s.Type = stringValue(rrs.Type)
+			s.SetID = stringValue(rrs.SetIdentifier)
+			if rrs.TTL != nil {
+				s.TTL = *rrs.TTL
+			}
+			s.Values = recordValues(*rrs)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+// currentUser names who ran the tool for change reports
+func currentUser() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return os.Getenv("USERNAME")
+}
+
+// notifyWebhook posts a submitted change batch to the -webhook URL.
+// The DNS change has already happened, so failures are only logged.
+func (c *cli) notifyWebhook(zoneID string, changes []route53.Change, info *route53.ChangeInfo) {
+	if c.webhook == "" {
+		return
+	}
+	event := changeEvent{
+		ZoneID:  zoneID,
+		User:    currentUser(),
+		Time:    time.Now().UTC(),
+		Changes: summarizeChanges(changes),
+	}
+	if info != nil {
+		event.ChangeID = stringValue(info.ID)
+		event.Status = stringValue(info.Status)
+	}
+	if err := postJSON(c.webhook, event); err != nil {
+		c.log.Println("WARNING webhook notification failed", err)
+	}
+}
+
+// postJSON sends v as a JSON POST body, treating non-2xx responses as errors
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+func TestNotifyWebhookPayload(t *testing.T) {
+	var got changeEvent
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("decoding %s: %s", body, err)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("USER", "alice")
+	c, logs, _ := newTestCLI(t, newFakeRoute53(nil))
+	c.webhook = server.URL
+	rrs := testRecordSet("www.example.com.", "A", 60, "192.0.2.1", "192.0.2.2")
+	rrs.SetIdentifier = aws.String("dc1")
+	changes := []route53.Change{{Action: aws.String("UPSERT"), ResourceRecordSet: &rrs}}
+	c.notifyWebhook("Z1", changes, &route53.ChangeInfo{ID: aws.String("/change/C1"), Status: aws.String("PENDING")})
+
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	want := changeEvent{
+		ZoneID:   "Z1",
+		ChangeID: "/change/C1",
+		Status:   "PENDING",
+		User:     "alice",
+		Time:     got.Time,
+		Changes: []changeSummary{
+			{Action: "UPSERT", Name: "www.example.com.", Type: "A", SetID: "dc1", TTL: 60, Values: []string{"192.0.2.1", "192.0.2.2"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("posted %+v, want %+v", got, want)
+	}
+	if got.Time.IsZero() {
+		t.Error("posted event has no time")
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %q for a successful notification", logs.String())
+	}
+}
+
+func TestPostJSONStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		err    bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNoContent, false},
+		{http.StatusMovedPermanently, true},
+		{http.StatusBadRequest, true},
+		{http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		err := postJSON(server.URL, map[string]string{"a": "b"})
+		server.Close()
+		if (err != nil) != tt.err {
+			t.Errorf("status %d: postJSON() = %v, want error %t", tt.status, err, tt.err)
+		}
+	}
+}
+
+func TestNotifyWebhookFailureOnlyWarns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	c, logs, _ := newTestCLI(t, newFakeRoute53(nil))
+	c.webhook = server.URL
+	c.notifyWebhook("Z1", nil, nil)
+	if !strings.Contains(logs.String(), "WARNING webhook notification failed") || !strings.Contains(logs.String(), "503") {
+		t.Errorf("logged %q, want a warning naming the 503", logs.String())
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const zoneCacheFile = "zones.json"
+
+// zoneCache remembers zone IDs by zone name between runs so the ListHostedZones lookup can be skipped
+type zoneCache struct {
+	path  string
+	Zones map[string]string `json:"zones"`
+}
+
+// loadZoneCache reads the zone cache, a missing file is treated as empty
+func loadZoneCache() (*zoneCache, error) {
+	zc := &zoneCache{
+		path:  filepath.Join(stateDir(), zoneCacheFile),
+		Zones: make(map[string]string),
+	}
+	data, err := ioutil.ReadFile(zc.path)
+	if os.IsNotExist(err) {
+		return zc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, zc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", zc.path, err)
+	}
+	if zc.Zones == nil {
+		zc.Zones = make(map[string]string)
+	}
+	return zc, nil
+}
+
+func (zc *zoneCache) get(zoneName string) (string, bool) {
+	id, exists := zc.Zones[zoneName]
+	return id, exists
+}
+
+func (zc *zoneCache) set(zoneName, zoneID string) error {
+	zc.Zones[zoneName] = zoneID
+	return zc.save()
+}
+
+func (zc *zoneCache) forget(zoneName string) error {
+	delete(zc.Zones, zoneName)
+	return zc.save()
+}
+
+func (zc *zoneCache) save() error {
+	data, err := json.MarshalIndent(zc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(zc.path), stateDirPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(zc.path, data, stateFilePermissions)
+}
+
+// isNoSuchHostedZone reports whether err says the zone ID used doesn't exist (any more)
+func isNoSuchHostedZone(err error) bool {
+	return apiErrorCode(err) == "NoSuchHostedZone"
+}
+
+// withZone runs fn with the zone for recordName, using zoneID when given and looking it up otherwise.
+// When a cached zone ID turns out to be stale the cache entry is dropped, the zone looked up again and fn retried once.
+func (c *cli) withZone(recordName, zoneID string, fn func(zone zoneRef) error) error {
+	if zoneID != "" {
+		return fn(zoneRef{id: zoneID})
+	}
+	zone, err := c.lookupZone(recordName)
+	if err != nil {
+		return zoneLookupError(err)
+	}
+	err = fn(zone)
+	if !zone.cached || !isNoSuchHostedZone(err) {
+		return err
+	}
+
+	c.log.Printf("cached zoneID=%s for %s no longer exists, looking it up again\n", zone.id, zone.name)
+	if err := c.zoneCache.forget(zone.name); err != nil {
+		c.log.Println("WARNING could not update zone cache", err)
+	}
+	zone, err = c.lookupZone(recordName)
+	if err != nil {
+		return zoneLookupError(err)
+	}
+	return fn(zone)
+}
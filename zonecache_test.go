@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWithZoneRetriesStaleCachedZoneOnce(t *testing.T) {
+	fake := newFakeRoute53(map[string]string{"Z2": "example.com."})
+	c, _, _ := newTestCLI(t, fake)
+	cache, err := loadZoneCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.zoneCache = cache
+	// the zone was deleted and created again under a new ID since it was cached
+	if err := c.zoneCache.set("example.com.", "Z1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tried []string
+	err = c.withZone("www.example.com", "", func(zone zoneRef) error {
+		tried = append(tried, zone.id)
+		_, err := c.listResourceRecordSets(zone.id, recordSetFilter{})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Z1", "Z2"}; !reflect.DeepEqual(tried, want) {
+		t.Errorf("ran with zones %v, want %v", tried, want)
+	}
+	if fake.listZonesCalls != 1 {
+		t.Errorf("%d ListHostedZones calls, want 1", fake.listZonesCalls)
+	}
+	reloaded, err := loadZoneCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := reloaded.get("example.com."); id != "Z2" {
+		t.Errorf("cached zoneID = %q, want Z2", id)
+	}
+}
+
+func TestWithZoneDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		cached bool
+		err    error
+	}{
+		// a zone looked up just now isn't stale, the error is passed on
+		{"looked up zone", false, nil},
+		{"other error", true, errors.New("throttled")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeRoute53(map[string]string{"Z2": "example.com."})
+			c, _, _ := newTestCLI(t, fake)
+			cache, err := loadZoneCache()
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.zoneCache = cache
+			if tt.cached {
+				c.zoneCache.set("example.com.", "Z2")
+			}
+			calls := 0
+			err = c.withZone("www.example.com", "", func(zone zoneRef) error {
+				calls++
+				if tt.err != nil {
+					return tt.err
+				}
+				// as if the zone was deleted right after it was looked up
+				_, err := c.listResourceRecordSets("Z9", recordSetFilter{})
+				return err
+			})
+			if err == nil {
+				t.Fatal("withZone() succeeded, want the error of fn")
+			}
+			if calls != 1 {
+				t.Errorf("fn ran %d times, want once", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+// zoneFileParser keeps the state carried between the records of a BIND zone file
+type zoneFileParser struct {
+	origin     string
+	defaultTTL int64
+	lastTTL    int64
+	lastOwner  string
+	sets       []route53.ResourceRecordSet
+	index      map[string]int
+}
+
+// parseZoneFile reads the record sets of a BIND zone file, relative names are completed with origin.
+// Records with the same name and type are grouped into one record set taking the TTL of the first.
+func parseZoneFile(r io.Reader, origin string) ([]route53.ResourceRecordSet, error) {
+	p := &zoneFileParser{origin: normalizeName(origin), index: make(map[string]int)}
+	scanner := bufio.NewScanner(r)
+	var (
+		record    string
+		startLine int
+		depth     int
+	)
+	for line := 1; scanner.Scan(); line++ {
+		text := stripZoneComment(scanner.Text())
+		if depth == 0 {
+			record, startLine = text, line
+		} else {
+			record += " " + text
+		}
+		depth += parenDepth(text)
+		if depth < 0 {
+			return nil, fmt.Errorf("line %d: unbalanced parentheses", line)
+		}
+		if depth > 0 {
+			continue
+		}
+		if err := p.parseRecord(record); err != nil {
+			return nil, fmt.Errorf("line %d: %s", startLine, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: unclosed parentheses", startLine)
+	}
+	return p.sets, nil
+}
+
+// parseRecord handles one logical line, a directive or a resource record
+func (p *zoneFileParser) parseRecord(record string) error {
+	fields := zoneFields(record)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "$ORIGIN":
+		if len(fields) != 2 {
+			return fmt.Errorf("$ORIGIN needs a single domain name")
+		}
+		p.origin = p.qualify(fields[1])
+		return nil
+	case "$TTL":
+		if len(fields) != 2 {
+			return fmt.Errorf("$TTL needs a single TTL")
+		}
+		ttl, ok := parseZoneTTL(fields[1])
+		if !ok {
+			return fmt.Errorf("invalid $TTL %q", fields[1])
+		}
+		p.defaultTTL = ttl
+		return nil
+	case "$INCLUDE", "$GENERATE":
+		return fmt.Errorf("%s is not supported", fields[0])
+	}
+
+	// a record starting with whitespace belongs to the previous owner
+	owner := p.lastOwner
+	if record[0] != ' ' && record[0] != '\t' {
+		owner = p.qualify(fields[0])
+		fields = fields[1:]
+	}
+	if owner == "" {
+		return fmt.Errorf("record without an owner name")
+	}
+	p.lastOwner = owner
+
+	ttl := int64(-1)
+	for len(fields) > 0 {
+		if t, ok := parseZoneTTL(fields[0]); ok && ttl < 0 {
+			ttl = t
+		} else if class := strings.ToUpper(fields[0]); class != "IN" && class != "CH" && class != "HS" {
+			break
+		}
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("record for %s has no type or data", owner)
+	}
+	switch {
+	case ttl >= 0:
+	case p.defaultTTL > 0:
+		ttl = p.defaultTTL
+	case p.lastTTL > 0:
+		ttl = p.lastTTL
+	default:
+		ttl = defaultTTL
+	}
+	p.lastTTL = ttl
+
+	recordType := normalizeType(fields[0])
+	value, err := p.recordValue(recordType, fields[1:])
+	if err != nil {
+		return fmt.Errorf("%s %s: %s", owner, recordType, err)
+	}
+	p.add(owner, recordType, ttl, value)
+	return nil
+}
+
+// recordValue builds the Route53 value of a record, completing the relative names found in its data
+func (p *zoneFileParser) recordValue(recordType string, data []string) (string, error) {
+	// index of the fields holding domain names, per type
+	var names []int
+	switch recordType {
+	case "CNAME", "NS", "PTR":
+		names = []int{0}
+	case "MX":
+		names = []int{1}
+	case "SRV":
+		names = []int{3}
+	case "SOA":
+		names = []int{0, 1}
+	}
+	for _, i := range names {
+		if i >= len(data) {
+			return "", fmt.Errorf("missing data")
+		}
+		data[i] = p.qualify(data[i])
+	}
+	value := strings.Join(data, " ")
+	if err := validateValue(recordType, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// add appends value to the record set for name and type, creating it when it's the first
+func (p *zoneFileParser) add(name, recordType string, ttl int64, value string) {
+	key := strings.ToLower(name) + " " + recordType
+	i, ok := p.index[key]
+	if !ok {
+		i = len(p.sets)
+		p.index[key] = i
+		p.sets = append(p.sets, route53.ResourceRecordSet{
+			Name: aws.String(name),
+			Type: aws.String(recordType),
+			TTL:  aws.Long(ttl),
+		})
+	}
+	p.sets[i].ResourceRecords = append(p.sets[i].ResourceRecords, route53.ResourceRecord{Value: aws.String(value)})
+}
+
+// qualify completes a relative name with the origin, "@" is the origin itself
+func (p *zoneFileParser) qualify(name string) string {
+	switch {
+	case name == "@":
+		return p.origin
+	case strings.HasSuffix(name, ".") && !strings.HasSuffix(name, `\.`):
+		return name
+	case p.origin == ".":
+		return name + "."
+	}
+	return name + "." + p.origin
+}
+
+// stripZoneComment removes a ; comment, leaving semicolons inside quoted strings alone
+func stripZoneComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// parenDepth returns how many parentheses outside quoted strings line opens minus how many it closes
+func parenDepth(line string) int {
+	depth := 0
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				depth++
+			}
+		case ')':
+			if !quoted {
+				depth--
+			}
+		}
+	}
+	return depth
+}
+
+// zoneFields splits a record into its fields, quoted strings are kept whole with their quotes
+func zoneFields(record string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	flush := func() {
+		if field.Len() > 0 {
+			fields = append(fields, field.String())
+			field.Reset()
+		}
+	}
+	for i := 0; i < len(record); i++ {
+		ch := record[i]
+		switch {
+		case ch == '\\' && i+1 < len(record):
+			field.WriteByte(ch)
+			i++
+			field.WriteByte(record[i])
+		case ch == '"':
+			field.WriteByte(ch)
+			quoted = !quoted
+		case quoted:
+			field.WriteByte(ch)
+		case ch == ' ' || ch == '\t' || ch == '(' || ch == ')':
+			flush()
+		default:
+			field.WriteByte(ch)
+		}
+	}
+	flush()
+	return fields
+}
+
+// parseZoneTTL parses a TTL in seconds or with BIND units, e.g. 3600 or 1h30m
+func parseZoneTTL(s string) (int64, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, n >= 0
+	}
+	units := map[byte]int64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	var total, n int64
+	digits := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= '0' && ch <= '9' {
+			n = n*10 + int64(ch-'0')
+			digits = true
+			continue
+		}
+		unit, ok := units[ch|0x20]
+		if !ok || !digits {
+			return 0, false
+		}
+		total += n * unit
+		n, digits = 0, false
+	}
+	if digits {
+		return 0, false
+	}
+	return total, true
+}
+
+// isApexManaged reports whether rrs is the SOA or NS record set of the zone apex, which Route53 creates itself
+func isApexManaged(rrs route53.ResourceRecordSet, zoneName string) bool {
+	switch stringValue(rrs.Type) {
+	case "SOA", "NS":
+		return sameName(stringValue(rrs.Name), zoneName)
+	}
+	return false
+}
+
+// importZone creates the record sets of a BIND zone file in the zone.
+// The apex SOA and NS record sets already exist in every hosted zone, they are skipped unless includeApex,
+// in which case they are replaced with the ones from the file.
+func (c *cli) importZone(zoneID, zoneName, path string, includeApex bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sets, err := parseZoneFile(f, zoneName)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %s", path, err)
+	}
+
+	var changes []route53.Change
+	counts := make(map[string]int)
+	skipped := 0
+	for i := range sets {
+		rrs := &sets[i]
+		action := "CREATE"
+		if isApexManaged(*rrs, zoneName) {
+			if !includeApex {
+				skipped++
+				continue
+			}
+			action = "UPSERT"
+		}
+		if err := c.validateRecordSet(*rrs); err != nil {
+			return err
+		}
+		changes = append(changes, route53.Change{Action: aws.String(action), ResourceRecordSet: rrs})
+		counts[stringValue(rrs.Type)]++
+	}
+	if skipped > 0 {
+		c.log.Printf("skipping %d apex SOA/NS record set(s) managed by Route53, use -import-apex to replace them\n", skipped)
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("%s has no record sets to import", path)
+	}
+	if err := c.submitChanges(zoneID, changes); err != nil {
+		return err
+	}
+
+	var types []string
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	var summary []string
+	for _, t := range types {
+		summary = append(summary, fmt.Sprintf("%s=%d", t, counts[t]))
+	}
+	verb := "imported"
+	if c.dryRun {
+		verb = "would import"
+	}
+	c.log.Printf("%s %d record set(s) into %s: %s\n", verb, len(changes), zoneName, strings.Join(summary, " "))
+	return nil
+}
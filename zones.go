@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+	"gopkg.in/yaml.v2"
+)
+
+// shortZoneID strips the /hostedzone/ prefix Route53 puts on zone IDs
+func shortZoneID(id string) (string, error) {
+	// zone.ID looks like /hostedzone/Z22CR2RGPPKRQB but we just want the last part
+	components := strings.Split(id, "/")
+	if len(components) != 3 {
+		return "", fmt.Errorf("problem splitting id from %s", id)
+	}
+	return components[len(components)-1], nil
+}
+
+// zoneIDPattern matches hosted zone IDs, with or without the /hostedzone/ prefix
+var zoneIDPattern = regexp.MustCompile(`^(/hostedzone/)?Z[A-Z0-9]{1,31}$`)
+
+// readZoneIDFile reads a hosted zone ID written to a file, e.g. by an earlier provisioning step
+func readZoneIDFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(data))
+	if !zoneIDPattern.MatchString(id) {
+		return "", fmt.Errorf("%s does not hold a hosted zone ID", path)
+	}
+	return strings.TrimPrefix(id, "/hostedzone/"), nil
+}
+
+// listHostedZones pages through every hosted zone in the account
+func (c *cli) listHostedZones() ([]route53.HostedZone, error) {
+	var zones []route53.HostedZone
+	req := &route53.ListHostedZonesRequest{}
+	for {
+		if err := c.checkDeadline(); err != nil {
+			return nil, err
+		}
+		resp, err := c.r53.ListHostedZones(req)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, resp.HostedZones...)
+		if resp.IsTruncated == nil || !*resp.IsTruncated {
+			return zones, nil
+		}
+		req.Marker = resp.NextMarker
+	}
+}
+
+// zoneSummary is the listed form of a hosted zone
+type zoneSummary struct {
+	Name    string `json:"name" yaml:"name"`
+	ID      string `json:"id" yaml:"id"`
+	Private bool   `json:"private" yaml:"private"`
+	Records int64  `json:"records" yaml:"records"`
+}
+
+func summarizeZone(zone route53.HostedZone) zoneSummary {
+	s := zoneSummary{Name: stringValue(zone.Name), ID: stringValue(zone.ID)}
+	if id, err := shortZoneID(s.ID); err == nil {
+		s.ID = id
+	}
+	if zone.Config != nil && zone.Config.PrivateZone != nil {
+		s.Private = *zone.Config.PrivateZone
+	}
+	if zone.ResourceRecordSetCount != nil {
+		s.Records = *zone.ResourceRecordSetCount
+	}
+	return s
+}
+
+// printZones writes the hosted zones in the requested output format, xml unless table, values, json or yaml.
+// options.validate refuses the formats describing record sets.
+func printZones(w io.Writer, format string, zones []route53.HostedZone) error {
+	var summaries []zoneSummary
+	for _, zone := range zones {
+		summaries = append(summaries, summarizeZone(zone))
+	}
+
+	switch format {
+	case outputTable:
+		rows := [][]string{{"NAME", "ID", "VISIBILITY", "RECORDS"}}
+		for _, s := range summaries {
+			visibility := "public"
+			if s.Private {
+				visibility = "private"
+			}
+			rows = append(rows, []string{s.Name, s.ID, visibility, fmt.Sprint(s.Records)})
+		}
+		return writeTable(w, colorEnabled(w), rows)
+	case outputValues:
+		for _, s := range summaries {
+			if _, err := fmt.Fprintln(w, s.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputJSON:
+		if summaries == nil {
+			summaries = []zoneSummary{}
+		}
+		return writeJSON(w, summaries)
+	case outputYAML:
+		data, err := yaml.Marshal(summaries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		for _, zone := range zones {
+			if err := enc.Encode(zone); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(w)
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/route53"
+)
+
+func TestPrintZones(t *testing.T) {
+	zones := []route53.HostedZone{
+		{ID: aws.String("/hostedzone/Z1"), Name: aws.String("example.com."), ResourceRecordSetCount: aws.Long(4)},
+		{ID: aws.String("/hostedzone/Z2"), Name: aws.String("internal.example."), ResourceRecordSetCount: aws.Long(2),
+			Config: &route53.HostedZoneConfig{PrivateZone: aws.Boolean(true)}},
+	}
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{outputTable, []string{"NAME", "VISIBILITY", "internal.example.  Z2  private     2"}},
+		{outputValues, []string{"Z1\nZ2\n"}},
+		{outputYAML, []string{"- name: example.com.\n  id: Z1\n  private: false\n  records: 4\n"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := printZones(&buf, tt.format, zones); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("-output=%s wrote\n%s\nwithout %q", tt.format, buf.String(), want)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printZones(&buf, outputJSON, zones); err != nil {
+		t.Fatal(err)
+	}
+	var summaries []zoneSummary
+	if err := json.Unmarshal(buf.Bytes(), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	want := zoneSummary{Name: "internal.example.", ID: "Z2", Private: true, Records: 2}
+	if len(summaries) != 2 || summaries[1] != want {
+		t.Errorf("-output=json wrote %+v, want the second zone as %+v", summaries, want)
+	}
+}